@@ -50,10 +50,16 @@ func init() {
 	usersDAO := &auth0.UsersDAO{
 		Manager: m,
 	}
+	weightsDAO := &postgres.WeightsDAO{
+		DB: db,
+	}
+	billingEventsDAO := &postgres.BillingEventsDAO{
+		DB: db,
+	}
 
 	// Setup services
 	handler = internal_http.NewHandler(
-		app.NewService(tracksDAO, kpisDAO, usersDAO),
+		app.NewService(tracksDAO, kpisDAO, usersDAO, weightsDAO, billingEventsDAO),
 		auth0Domain,
 		auth0ApiID,
 	)
@@ -1,14 +1,64 @@
 package app
 
-import "time"
+import (
+	"time"
+)
 
 type PosAggregate struct {
 	Value    string    `json:"value" db:"value"`
+	Values   []string  `json:"values" db:"-"` // the value of each dimension
 	Position int64     `json:"position" db:"position"`
 	Count    int64     `json:"count" db:"count"`
 	Day      time.Time `json:"day" db:"day"`
 }
 
+// CreditAggregate is the conversion credit and revenue an attribution model
+// gave to a value on a given day
+type CreditAggregate struct {
+	Value       string    `json:"value"`
+	Values      []string  `json:"values"` // the value of each dimension
+	Day         time.Time `json:"day"`
+	Conversions float64   `json:"conversions"`
+	Revenue     float64   `json:"revenue"`
+}
+
+// ModelAttribution is the conversion credit and revenue each value earned
+// under one attribution model
+type ModelAttribution struct {
+	ModelID      string             `json:"modelId"`
+	Conversions  map[string]float64 `json:"conversions"`
+	Revenue      map[string]float64 `json:"revenue"`
+	ModelDetails interface{}        `json:"modelDetails,omitempty"`
+}
+
+// TimeseriesPoint is a kpi's conversions in one period and the credit each
+// value earned for them
+type TimeseriesPoint struct {
+	Period      time.Time          `json:"period" db:"period"`
+	Conversions int64              `json:"conversions" db:"conversions"`
+	Credit      map[string]float64 `json:"credit" db:"-"`
+}
+
+// DateRange limits aggregates to conversions sent from From until To. Either
+// can be left zero to leave that side open. Days are bucketed in Location.
+type DateRange struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+}
+
+// JourneyTouch is a track that happened before a conversion
+type JourneyTouch struct {
+	AnonymousID  string     `db:"anonymous_id"`
+	Value        string     `db:"value"`
+	Values       []string   `db:"-"` // the value of each dimension
+	SentAt       time.Time  `db:"sent_at"`
+	ConversionAt time.Time  `db:"conversion_sent_at"`
+	LeadAt       *time.Time `db:"lead_sent_at"` // when the visitor first matched the kpi's lead pattern, if ever
+	Converted    bool       `db:"converted"`    // only set when loading every visitor's journey
+	Revenue      float64    `db:"revenue"`      // what the conversion was worth, only set when loading conversion journeys
+}
+
 type User struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
@@ -19,6 +69,7 @@ type User struct {
 type Track struct {
 	ID              int64     `json:"id" db:"id"`
 	OwnerID         string    `json:"ownerId" db:"owner_id"`
+	MessageID       string    `json:"messageId" db:"message_id"` // optional, unique per owner so retries aren't stored twice
 	UserID          string    `json:"userId" db:"user_id"`
 	AnonymousID     string    `json:"anonymousId" db:"anonymous_id"` // fingerprint hash
 	PageURL         string    `json:"pageURL" db:"page_url"`         // optional (website specific)
@@ -31,23 +82,88 @@ type Track struct {
 	CampaignMedium  string    `json:"campaignMedium" db:"campaign_medium"`
 	CampaignName    string    `json:"campaignName" db:"campaign_name"`
 	CampaignContent string    `json:"campaignContent" db:"campaign_content"`
+	Revenue         float64   `json:"revenue" db:"revenue"` // what the track was worth if it was a conversion
 	SentAt          time.Time `json:"sentAt" db:"sent_at"`
 	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
 }
 
+// BillingEvent is a payment made by one of an owner's users, which adds to the
+// revenue of that user's conversions
+type BillingEvent struct {
+	ID        int64     `json:"id" db:"id"`
+	OwnerID   string    `json:"-" db:"owner_id"`
+	UserID    string    `json:"userId" db:"user_id"`
+	Amount    float64   `json:"amount" db:"amount"`
+	SentAt    time.Time `json:"sentAt" db:"sent_at"`
+	CreatedAt time.Time `json:"-" db:"created_at"`
+}
+
+// trackColumns are the text columns of the tracks table that kpis can match on
+var trackColumns = map[string]bool{
+	"user_id":          true,
+	"anonymous_id":     true,
+	"page_url":         true,
+	"page_path":        true,
+	"page_title":       true,
+	"page_referrer":    true,
+	"event":            true,
+	"ip":               true,
+	"campaign_source":  true,
+	"campaign_medium":  true,
+	"campaign_name":    true,
+	"campaign_content": true,
+}
+
+// IsTrackColumn reports whether name is a text column of the tracks table that kpis can match on
+func IsTrackColumn(name string) bool {
+	return trackColumns[name]
+}
+
 // Kpi stores rules that can be matched on and recorded as conversions
 type Kpi struct {
-	ID                     int64     `json:"id" db:"id"`
-	OwnerID                string    `json:"-" db:"owner_id"`
-	ModelID                string    `json:"modelId" db:"model_id"`
-	Name                   string    `json:"name" db:"name"`
-	Target                 int64     `json:"target" db:"target"`
-	DataWasChanged         bool      `json:"-" db:"-"`
-	PatternMatchColumnName string    `json:"column" db:"pattern_match_column_name"`
-	PatternMatchRowValue   string    `json:"value" db:"pattern_match_row_value"`
-	CreatedAt              time.Time `json:"-" db:"created_at"`
-	// Fields that are added on get
-	CampaignNameJourneyAggregate []PosAggregate `json:"campaignNameJourneyAggregate" db:"-"`
+	ID                     int64       `json:"id" db:"id"`
+	OwnerID                string      `json:"-" db:"owner_id"`
+	ModelID                string      `json:"modelId" db:"model_id"`
+	Name                   string      `json:"name" db:"name"`
+	Target                 int64       `json:"target" db:"target"`
+	TargetPeriod           string      `json:"targetPeriod" db:"target_period"` // weekly, monthly or quarterly
+	DataWasChanged         bool        `json:"-" db:"-"`
+	PatternMatchColumnName string      `json:"column" db:"pattern_match_column_name"`
+	PatternMatchRowValue   string      `json:"value" db:"pattern_match_row_value"`
+	Condition              *Condition  `json:"condition,omitempty" db:"condition"`        // used instead of column and value when set
+	Steps                  FunnelSteps `json:"steps,omitempty" db:"steps"`                // makes the kpi a funnel, converting on the last step
+	FunnelWindowDays       int64       `json:"funnelWindowDays" db:"funnel_window_days"`  // how long visitors have to complete a funnel, 0 for no limit
+	Dimension              string      `json:"dimension" db:"dimension"`                  // comma separated track columns conversions are attributed to
+	LookbackDays           int64       `json:"lookbackDays" db:"lookback_days"`           // touches this long before a conversion are left out, 0 for no limit
+	RepeatConversions      bool        `json:"repeatConversions" db:"repeat_conversions"` // count every conversion instead of each visitor's latest
+	CreatedAt              time.Time   `json:"-" db:"created_at"`
+	// Attribution model settings
+	HalfLifeDays               float64 `json:"halfLifeDays" db:"half_life_days"`               // time-decay
+	FirstTouchWeight           float64 `json:"firstTouchWeight" db:"first_touch_weight"`       // u-shaped and w-shaped
+	LeadTouchWeight            float64 `json:"leadTouchWeight" db:"lead_touch_weight"`         // w-shaped
+	LastTouchWeight            float64 `json:"lastTouchWeight" db:"last_touch_weight"`         // u-shaped and w-shaped
+	LeadPatternMatchColumnName string  `json:"leadColumn" db:"lead_pattern_match_column_name"` // w-shaped
+	LeadPatternMatchRowValue   string  `json:"leadValue" db:"lead_pattern_match_row_value"`    // w-shaped
+	// Fields that are added on get. The journey aggregate keeps the json name the
+	// dashboard reads, from when it was always by campaign name, but is by the kpi's dimension.
+	// The aggregates are pointers so kpis listed without them leave them out, while kpis
+	// without conversions still have empty lists.
+	JourneyAggregate *[]PosAggregate    `json:"campaignNameJourneyAggregate,omitempty" db:"-"`
+	Attribution      *[]CreditAggregate `json:"attribution,omitempty" db:"-"`
+	ModelDetails     interface{}        `json:"modelDetails,omitempty" db:"-"` // what data-driven models learnt
+	Progress         *TargetProgress    `json:"progress,omitempty" db:"-"`     // only for kpis with a target
+	Error            string             `json:"error,omitempty" db:"-"`        // why aggregates couldn't be added
+}
+
+// Weight is how much credit the weighted model gives to a value of a track
+// column, relative to the other values
+type Weight struct {
+	ID         int64     `json:"id" db:"id"`
+	OwnerID    string    `json:"-" db:"owner_id"`
+	ColumnName string    `json:"column" db:"column_name"`
+	Value      string    `json:"value" db:"value"`
+	Weight     float64   `json:"weight" db:"weight"`
+	CreatedAt  time.Time `json:"-" db:"created_at"`
 }
 
 type UsersDAO interface {
@@ -56,13 +172,33 @@ type UsersDAO interface {
 
 type TracksDAO interface {
 	Store(t Track) (int64, error)
-	GetNormalizedJourneyAggregate(ownerID string, columnName, conversionColumnName, conversionRowValue string) ([]PosAggregate, error)
-	// GetNormalizedJourneyDailyAggregate(ownerID string, columnName, conversionColumnName, conversionRowValue string) ()
+	StoreBatch(tracks []Track) ([]int64, error)
+	GetNormalizedJourneyAggregate(kpi Kpi, dimensions []string, dateRange DateRange) ([]PosAggregate, error)
+	GetConversionJourneys(kpi Kpi, dimensions []string, dateRange DateRange) ([]JourneyTouch, error)
+	GetVisitorJourneys(kpi Kpi, dimensions []string, dateRange DateRange) ([]JourneyTouch, error)
+	GetConversionTimeseries(kpi Kpi, granularity string, dateRange DateRange) ([]TimeseriesPoint, error)
+	GetFunnelTouches(kpi Kpi, dateRange DateRange) ([]FunnelTouch, error)
+	GetTouchesForVisitors(ownerID string, dimensions []string, anonymousIDs []string) ([]JourneyTouch, error)
+	GetFunnelRevenues(kpi Kpi, anonymousIDs []string, completedAt []time.Time) ([]FunnelRevenue, error)
+	CheckRegex(pattern string) error
+}
+
+type BillingEventsDAO interface {
+	Store(e BillingEvent) (int64, error)
 }
 
 type KpisDAO interface {
 	Store(kpi Kpi) (int64, error)
+	FindByID(id int64, ownerID string) (Kpi, error)
 	FindByOwnerID(ownerID string) ([]Kpi, error)
 	Update(kpi Kpi) error
 	Delete(id int64, ownerID string) (int64, error)
 }
+
+type WeightsDAO interface {
+	Store(weight Weight) (int64, error)
+	FindByOwnerID(ownerID, columnName string) ([]Weight, error)
+	FindUnweightedValues(ownerID, columnName string) ([]string, error)
+	Update(weight Weight) error
+	Delete(id int64, ownerID string) (int64, error)
+}
@@ -0,0 +1,274 @@
+package app
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// journey is every touch that led up to a single conversion, oldest first
+type journey []JourneyTouch
+
+// attributionModel returns the share of a conversion that each touch in the
+// journey is credited with
+type attributionModel func(kpi Kpi, j journey) []float64
+
+var attributionModels = map[string]attributionModel{
+	FirstTouchModelID: firstTouch,
+	LastTouchModelID:  lastTouch,
+	LinearModelID:     linear,
+	TimeDecayModelID:  timeDecay,
+	UShapedModelID:    uShaped,
+	WShapedModelID:    wShaped,
+}
+
+// dataDrivenModel learns from the journeys of every visitor, converting or
+// not, and returns a model to credit conversions with along with what it learnt
+type dataDrivenModel func(visitors []journey) (attributionModel, interface{})
+
+var dataDrivenModels = map[string]dataDrivenModel{
+	MarkovModelID:  markovModel,
+	ShapleyModelID: shapleyModel,
+}
+
+func modelExists(modelID string) bool {
+	_, ok := attributionModels[modelID]
+	_, dataDriven := dataDrivenModels[modelID]
+	return ok || dataDriven || modelID == WeightedModelID
+}
+
+// firstTouch credits the whole conversion to the first touch
+func firstTouch(kpi Kpi, j journey) []float64 {
+	credit := make([]float64, len(j))
+	credit[0] = 1
+	return credit
+}
+
+// lastTouch credits the whole conversion to the final touch before it
+func lastTouch(kpi Kpi, j journey) []float64 {
+	credit := make([]float64, len(j))
+	credit[len(j)-1] = 1
+	return credit
+}
+
+// linear splits the conversion equally across every touch
+func linear(kpi Kpi, j journey) []float64 {
+	credit := make([]float64, len(j))
+	for i := range credit {
+		credit[i] = 1 / float64(len(j))
+	}
+	return credit
+}
+
+// timeDecay gives touches exponentially more credit the closer they are to the
+// conversion, halving every kpi.HalfLifeDays
+func timeDecay(kpi Kpi, j journey) []float64 {
+	halfLife := kpi.HalfLifeDays
+	if halfLife <= 0 {
+		halfLife = DefaultHalfLifeDays
+	}
+
+	// Ages are relative to the newest touch, so that it always has a weight of 1
+	// rather than every weight underflowing to 0 for short half-lives
+	newest := j[0].SentAt
+	for _, t := range j {
+		if t.SentAt.After(newest) {
+			newest = t.SentAt
+		}
+	}
+
+	credit := make([]float64, len(j))
+	total := 0.0
+	for i, t := range j {
+		age := newest.Sub(t.SentAt).Hours() / 24
+		credit[i] = math.Pow(2, -age/halfLife)
+		total += credit[i]
+	}
+	for i := range credit {
+		credit[i] /= total
+	}
+	return credit
+}
+
+// uShaped credits the first and last touches with fixed weights (40% each by
+// default) and splits the rest across the touches in between
+func uShaped(kpi Kpi, j journey) []float64 {
+	first, last := kpi.FirstTouchWeight, kpi.LastTouchWeight
+	if first == 0 && last == 0 {
+		first, last = DefaultUShapedEdgeWeight, DefaultUShapedEdgeWeight
+	}
+
+	weights := map[int]float64{}
+	weights[0] += first
+	weights[len(j)-1] += last
+
+	return positionBased(len(j), weights)
+}
+
+// wShaped is like uShaped, but also gives a fixed weight to the touch that
+// created the lead, which is the last touch at or before the visitor matched
+// the kpi's lead pattern
+func wShaped(kpi Kpi, j journey) []float64 {
+	first, lead, last := kpi.FirstTouchWeight, kpi.LeadTouchWeight, kpi.LastTouchWeight
+	if first == 0 && lead == 0 && last == 0 {
+		first, lead, last = DefaultWShapedPositionWeight, DefaultWShapedPositionWeight, DefaultWShapedPositionWeight
+	}
+
+	weights := map[int]float64{}
+	weights[0] += first
+	if i := leadTouchIndex(j); i >= 0 {
+		weights[i] += lead
+	}
+	weights[len(j)-1] += last
+
+	return positionBased(len(j), weights)
+}
+
+// leadTouchIndex returns the index of the touch that created the lead, or -1 if
+// the visitor didn't become a lead before converting
+func leadTouchIndex(j journey) int {
+	leadAt := j[0].LeadAt
+	if leadAt == nil || !leadAt.Before(j[0].ConversionAt) {
+		return -1
+	}
+
+	index := -1
+	for i, t := range j {
+		if t.SentAt.After(*leadAt) {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+// positionBased gives the touches at the keys of weights a fixed share of the
+// conversion and splits the rest equally across every other touch. If there
+// are no other touches, the fixed shares are scaled up to cover the conversion.
+func positionBased(n int, weights map[int]float64) []float64 {
+	credit := make([]float64, n)
+	assigned := 0.0
+	for i, w := range weights {
+		credit[i] += w
+		assigned += w
+	}
+
+	if others := n - len(weights); others > 0 {
+		for i := range credit {
+			if _, ok := weights[i]; !ok {
+				credit[i] = (1 - assigned) / float64(others)
+			}
+		}
+		return credit
+	}
+
+	for i := range credit {
+		if assigned == 0 {
+			credit[i] = 1 / float64(n)
+			continue
+		}
+		credit[i] /= assigned
+	}
+	return credit
+}
+
+// weightedModel credits each touch in proportion to the weight its owner gave
+// the touch's value. Journeys with no weighted values are split equally.
+func weightedModel(weights []Weight) attributionModel {
+	byValue := map[string]float64{}
+	for _, w := range weights {
+		byValue[w.Value] = w.Weight
+	}
+
+	return func(kpi Kpi, j journey) []float64 {
+		credit := make([]float64, len(j))
+		total := 0.0
+		for i, t := range j {
+			credit[i] = byValue[t.Value]
+			total += credit[i]
+		}
+		if total == 0 {
+			return linear(kpi, j)
+		}
+		for i := range credit {
+			credit[i] /= total
+		}
+		return credit
+	}
+}
+
+// groupJourneys splits touches, ordered by visitor, conversion and then
+// sent_at, into one journey per conversion
+func groupJourneys(touches []JourneyTouch) []journey {
+	var journeys []journey
+	for i, t := range touches {
+		prev := i - 1
+		if i == 0 || t.AnonymousID != touches[prev].AnonymousID || !t.ConversionAt.Equal(touches[prev].ConversionAt) {
+			journeys = append(journeys, journey{})
+		}
+		last := len(journeys) - 1
+		journeys[last] = append(journeys[last], t)
+	}
+	return journeys
+}
+
+// attribute runs the model over every journey and sums up the credit and
+// revenue each value earned per day it was touched on
+func attribute(kpi Kpi, model attributionModel, touches []JourneyTouch) []CreditAggregate {
+	return attributeBy(kpi, model, touches, func(t JourneyTouch) time.Time {
+		return truncateDay(t.SentAt)
+	})
+}
+
+// attributeBy runs the model over every journey and sums up the credit and
+// revenue each value earned per bucket, where the bucket of each touch is its Day.
+// Revenue is split between a journey's touches in proportion to their credit.
+func attributeBy(kpi Kpi, model attributionModel, touches []JourneyTouch, bucket func(JourneyTouch) time.Time) []CreditAggregate {
+	type key struct {
+		value string
+		day   time.Time
+	}
+	credits := map[key]float64{}
+	revenue := map[key]float64{}
+	values := map[string][]string{}
+	keys := []key{}
+
+	for _, j := range groupJourneys(touches) {
+		for i, credit := range model(kpi, j) {
+			if credit == 0 {
+				continue
+			}
+			k := key{value: j[i].Value, day: bucket(j[i])}
+			if _, ok := credits[k]; !ok {
+				keys = append(keys, k)
+				values[k.value] = j[i].Values
+			}
+			credits[k] += credit
+			revenue[k] += credit * j[i].Revenue
+		}
+	}
+
+	sort.Slice(keys, func(a, b int) bool {
+		if !keys[a].day.Equal(keys[b].day) {
+			return keys[a].day.Before(keys[b].day)
+		}
+		return keys[a].value < keys[b].value
+	})
+
+	aggregates := make([]CreditAggregate, 0, len(keys))
+	for _, k := range keys {
+		aggregates = append(aggregates, CreditAggregate{
+			Value:       k.value,
+			Values:      values[k.value],
+			Day:         k.day,
+			Conversions: credits[k],
+			Revenue:     revenue[k],
+		})
+	}
+
+	return aggregates
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
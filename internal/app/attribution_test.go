@@ -0,0 +1,203 @@
+package app
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	day1       = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	day2       = day1.Add(24 * time.Hour)
+	day3       = day2.Add(24 * time.Hour)
+	conversion = day3.Add(time.Hour)
+)
+
+func TestGroupJourneys(t *testing.T) {
+
+	t.Run("groupJourneys splits touches by visitor and conversion", func(t *testing.T) {
+		touches := []JourneyTouch{
+			{AnonymousID: "a", Value: "Paid Search", SentAt: day1, ConversionAt: conversion},
+			{AnonymousID: "a", Value: "Blog", SentAt: day2, ConversionAt: conversion},
+			{AnonymousID: "b", Value: "Blog", SentAt: day1, ConversionAt: conversion},
+		}
+
+		journeys := groupJourneys(touches)
+
+		if len(journeys) != 2 {
+			t.Fatalf("groupJourneys returned wrong number of journeys: got %v want %v",
+				len(journeys), 2)
+		}
+		if len(journeys[0]) != 2 || len(journeys[1]) != 1 {
+			t.Errorf("groupJourneys returned wrong journey lengths: got %v and %v want 2 and 1",
+				len(journeys[0]), len(journeys[1]))
+		}
+	})
+}
+
+func TestAttribute(t *testing.T) {
+	touches := []JourneyTouch{
+		{AnonymousID: "a", Value: "Paid Search", SentAt: day1, ConversionAt: conversion},
+		{AnonymousID: "a", Value: "Blog", SentAt: day2, ConversionAt: conversion},
+		{AnonymousID: "b", Value: "Social", SentAt: day1, ConversionAt: conversion},
+		{AnonymousID: "b", Value: "Blog", SentAt: day3, ConversionAt: conversion},
+	}
+
+	t.Run("first-touch credits the first touch of every journey", func(t *testing.T) {
+		expected := []CreditAggregate{
+			{Value: "Paid Search", Day: day1, Conversions: 1},
+			{Value: "Social", Day: day1, Conversions: 1},
+		}
+
+		aggregates := attribute(Kpi{}, firstTouch, touches)
+
+		if !reflect.DeepEqual(aggregates, expected) {
+			t.Errorf("attribute returned unexpected aggregates: got %+v want %+v",
+				aggregates, expected)
+		}
+	})
+
+	t.Run("last-touch credits the final touch of every journey", func(t *testing.T) {
+		expected := []CreditAggregate{
+			{Value: "Blog", Day: day2, Conversions: 1},
+			{Value: "Blog", Day: day3, Conversions: 1},
+		}
+
+		aggregates := attribute(Kpi{}, lastTouch, touches)
+
+		if !reflect.DeepEqual(aggregates, expected) {
+			t.Errorf("attribute returned unexpected aggregates: got %+v want %+v",
+				aggregates, expected)
+		}
+	})
+
+	t.Run("linear splits every conversion equally across its touches", func(t *testing.T) {
+		expected := []CreditAggregate{
+			{Value: "Paid Search", Day: day1, Conversions: 0.5},
+			{Value: "Social", Day: day1, Conversions: 0.5},
+			{Value: "Blog", Day: day2, Conversions: 0.5},
+			{Value: "Blog", Day: day3, Conversions: 0.5},
+		}
+
+		aggregates := attribute(Kpi{}, linear, touches)
+
+		if !reflect.DeepEqual(aggregates, expected) {
+			t.Errorf("attribute returned unexpected aggregates: got %+v want %+v",
+				aggregates, expected)
+		}
+	})
+
+	t.Run("revenue is split in proportion to credit", func(t *testing.T) {
+		withRevenue := []JourneyTouch{
+			{AnonymousID: "a", Value: "Paid Search", SentAt: day1, ConversionAt: conversion, Revenue: 100},
+			{AnonymousID: "a", Value: "Blog", SentAt: day1, ConversionAt: conversion, Revenue: 100},
+		}
+		expected := []CreditAggregate{
+			{Value: "Blog", Day: day1, Conversions: 0.5, Revenue: 50},
+			{Value: "Paid Search", Day: day1, Conversions: 0.5, Revenue: 50},
+		}
+
+		aggregates := attribute(Kpi{}, linear, withRevenue)
+
+		if !reflect.DeepEqual(aggregates, expected) {
+			t.Errorf("attribute returned unexpected aggregates: got %+v want %+v",
+				aggregates, expected)
+		}
+	})
+
+	t.Run("time-decay halves a touch's weight every half-life before the conversion", func(t *testing.T) {
+		kpi := Kpi{HalfLifeDays: 1}
+		j := journey{
+			{AnonymousID: "a", Value: "Paid Search", SentAt: conversion.Add(-48 * time.Hour), ConversionAt: conversion},
+			{AnonymousID: "a", Value: "Blog", SentAt: conversion.Add(-24 * time.Hour), ConversionAt: conversion},
+			{AnonymousID: "a", Value: "Social", SentAt: conversion, ConversionAt: conversion},
+		}
+		expected := []float64{1.0 / 7, 2.0 / 7, 4.0 / 7}
+
+		credit := timeDecay(kpi, j)
+
+		for i := range expected {
+			if math.Abs(credit[i]-expected[i]) > 1e-9 {
+				t.Errorf("timeDecay returned unexpected credit: got %v want %v",
+					credit, expected)
+				break
+			}
+		}
+	})
+
+	t.Run("time-decay still credits journeys with a tiny half-life", func(t *testing.T) {
+		kpi := Kpi{HalfLifeDays: 1e-9}
+		j := journey{
+			{AnonymousID: "a", Value: "Paid Search", SentAt: conversion.Add(-48 * time.Hour), ConversionAt: conversion},
+			{AnonymousID: "a", Value: "Blog", SentAt: conversion.Add(-24 * time.Hour), ConversionAt: conversion},
+		}
+		expected := []float64{0, 1}
+
+		credit := timeDecay(kpi, j)
+
+		if !reflect.DeepEqual(credit, expected) {
+			t.Errorf("timeDecay returned unexpected credit: got %v want %v",
+				credit, expected)
+		}
+	})
+
+	t.Run("u-shaped gives the edges 40% each and splits the rest across the middle", func(t *testing.T) {
+		j := journey{
+			{Value: "Paid Search", SentAt: day1, ConversionAt: conversion},
+			{Value: "Blog", SentAt: day2, ConversionAt: conversion},
+			{Value: "Social", SentAt: day3, ConversionAt: conversion},
+		}
+		expected := []float64{0.4, 0.2, 0.4}
+
+		credit := uShaped(Kpi{}, j)
+
+		for i := range expected {
+			if math.Abs(credit[i]-expected[i]) > 1e-9 {
+				t.Errorf("uShaped returned unexpected credit: got %v want %v",
+					credit, expected)
+				break
+			}
+		}
+	})
+
+	t.Run("w-shaped credits the touch that created the lead", func(t *testing.T) {
+		leadAt := day2.Add(time.Hour)
+		j := journey{
+			{Value: "Paid Search", SentAt: day1, ConversionAt: conversion, LeadAt: &leadAt},
+			{Value: "Blog", SentAt: day2, ConversionAt: conversion, LeadAt: &leadAt},
+			{Value: "Social", SentAt: day2.Add(2 * time.Hour), ConversionAt: conversion, LeadAt: &leadAt},
+			{Value: "Blog", SentAt: day3, ConversionAt: conversion, LeadAt: &leadAt},
+		}
+		expected := []float64{0.3, 0.3, 0.1, 0.3}
+
+		credit := wShaped(Kpi{}, j)
+
+		for i := range expected {
+			if math.Abs(credit[i]-expected[i]) > 1e-9 {
+				t.Errorf("wShaped returned unexpected credit: got %v want %v",
+					credit, expected)
+				break
+			}
+		}
+	})
+
+	t.Run("weighted credits touches in proportion to their value's weight", func(t *testing.T) {
+		model := weightedModel([]Weight{
+			{Value: "Paid Search", Weight: 3},
+			{Value: "Blog", Weight: 1},
+		})
+		expected := []CreditAggregate{
+			{Value: "Paid Search", Day: day1, Conversions: 0.75},
+			{Value: "Blog", Day: day2, Conversions: 0.25},
+			{Value: "Blog", Day: day3, Conversions: 1},
+		}
+
+		aggregates := attribute(Kpi{}, model, touches)
+
+		if !reflect.DeepEqual(aggregates, expected) {
+			t.Errorf("attribute returned unexpected aggregates: got %+v want %+v",
+				aggregates, expected)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Operators a condition can compare a track column with
+const (
+	EqualsOperator   = "equals"
+	ContainsOperator = "contains"
+	PrefixOperator   = "prefix"
+	RegexOperator    = "regex"
+	InOperator       = "in"
+	// MaxConditionDepth is how deeply conditions can be nested in each other
+	MaxConditionDepth = 5
+	// MaxConditionValues is how many values an in condition can list
+	MaxConditionValues = 100
+)
+
+var (
+	ErrInvalidCondition = ValidationError("The condition you sent is invalid. Each condition needs either and, or, not or a column, operator and value.")
+	ErrInvalidRegex     = ValidationError("The regex you sent is not a valid postgres regular expression.")
+)
+
+// Condition is a boolean condition over a track's columns. It is either a
+// list of conditions that must all (and) or any (or) be true, a condition
+// that must be false (not) or a comparison of a column with an operand.
+type Condition struct {
+	And      []Condition `json:"and,omitempty"`
+	Or       []Condition `json:"or,omitempty"`
+	Not      *Condition  `json:"not,omitempty"`
+	Column   string      `json:"column,omitempty"`
+	Operator string      `json:"operator,omitempty"`
+	Operand  string      `json:"value,omitempty"`
+	Operands []string    `json:"values,omitempty"` // in
+}
+
+// Value stores the condition as JSON
+func (c Condition) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan loads a condition stored as JSON
+func (c *Condition) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	}
+	return errors.New("condition must be stored as JSON")
+}
+
+// MatchCondition returns the condition tracks must meet to be one of the kpi's
+// conversions. Funnels convert on their last step, and kpis without a condition
+// convert when their column equals their value.
+func (kpi Kpi) MatchCondition() Condition {
+	if len(kpi.Steps) > 0 {
+		return kpi.Steps[len(kpi.Steps)-1]
+	}
+	if kpi.Condition != nil {
+		return *kpi.Condition
+	}
+	return Condition{
+		Column:   kpi.PatternMatchColumnName,
+		Operator: EqualsOperator,
+		Operand:  kpi.PatternMatchRowValue,
+	}
+}
+
+// validateCondition checks that the condition is well formed and only compares
+// track columns
+func validateCondition(c Condition, depth int) error {
+	if depth > MaxConditionDepth {
+		return ErrInvalidCondition
+	}
+
+	kinds := 0
+	for _, set := range []bool{c.And != nil, c.Or != nil, c.Not != nil, c.Column != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return ErrInvalidCondition
+	}
+
+	children := append(append([]Condition{}, c.And...), c.Or...)
+	if c.Not != nil {
+		children = append(children, *c.Not)
+	}
+	if c.Column == "" && len(children) == 0 {
+		return ErrInvalidCondition
+	}
+	for _, child := range children {
+		if err := validateCondition(child, depth+1); err != nil {
+			return err
+		}
+	}
+	if c.Column == "" {
+		return nil
+	}
+
+	if !IsTrackColumn(c.Column) {
+		return ErrUnknownColumn
+	}
+	switch c.Operator {
+	case EqualsOperator, ContainsOperator, PrefixOperator, RegexOperator:
+		// Regexes are run by postgres, so the service has it check them
+	case InOperator:
+		if len(c.Operands) == 0 || len(c.Operands) > MaxConditionValues {
+			return ErrInvalidCondition
+		}
+	default:
+		return ErrInvalidCondition
+	}
+
+	return nil
+}
+
+// conditionRegexes returns the pattern of every regex comparison in the conditions
+func conditionRegexes(conditions ...Condition) []string {
+	patterns := []string{}
+	for _, c := range conditions {
+		if c.Operator == RegexOperator {
+			patterns = append(patterns, c.Operand)
+		}
+		patterns = append(patterns, conditionRegexes(c.And...)...)
+		patterns = append(patterns, conditionRegexes(c.Or...)...)
+		if c.Not != nil {
+			patterns = append(patterns, conditionRegexes(*c.Not)...)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateCondition(t *testing.T) {
+	equals := Condition{Column: "event", Operator: EqualsOperator, Operand: "signup"}
+
+	tests := []struct {
+		name      string
+		condition Condition
+		expected  error
+	}{
+		{"a comparison is valid", equals, nil},
+		{"nested conditions are valid", Condition{Or: []Condition{equals, {Not: &equals}}}, nil},
+		{"empty conditions are invalid", Condition{}, ErrInvalidCondition},
+		{"empty lists are invalid", Condition{And: []Condition{}}, ErrInvalidCondition},
+		{"conditions with two kinds are invalid", Condition{And: []Condition{equals}, Column: "event"}, ErrInvalidCondition},
+		{"unknown columns are invalid", Condition{Column: "owner", Operator: EqualsOperator}, ErrUnknownColumn},
+		{"time columns are invalid", Condition{Column: "sent_at", Operator: EqualsOperator}, ErrUnknownColumn},
+		{"numeric columns are invalid", Condition{Column: "revenue", Operator: EqualsOperator}, ErrUnknownColumn},
+		{"internal columns are invalid", Condition{Column: "owner_id", Operator: EqualsOperator}, ErrUnknownColumn},
+		{"unknown operators are invalid", Condition{Column: "event", Operator: "like"}, ErrInvalidCondition},
+		{"regexes are checked by postgres instead", Condition{Column: "event", Operator: RegexOperator, Operand: "("}, nil},
+		{"in needs operands", Condition{Column: "event", Operator: InOperator}, ErrInvalidCondition},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateCondition(test.condition, 0); err != test.expected {
+				t.Errorf("validateCondition returned wrong error: got %v want %v",
+					err, test.expected)
+			}
+		})
+	}
+}
+
+func TestConditionRegexes(t *testing.T) {
+	regex := func(pattern string) Condition {
+		return Condition{Column: "page_path", Operator: RegexOperator, Operand: pattern}
+	}
+	equals := Condition{Column: "event", Operator: EqualsOperator, Operand: "signup"}
+	conditions := []Condition{
+		regex("^/blog"),
+		{And: []Condition{equals, {Or: []Condition{regex("a+"), {Not: &Condition{And: []Condition{regex("b*")}}}}}}},
+		equals,
+	}
+
+	t.Run("conditionRegexes finds nested regexes", func(t *testing.T) {
+		patterns := conditionRegexes(conditions...)
+
+		expected := []string{"^/blog", "a+", "b*"}
+		if !reflect.DeepEqual(patterns, expected) {
+			t.Errorf("conditionRegexes returned wrong patterns: got %v want %v",
+				patterns, expected)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// MinFunnelSteps and MaxFunnelSteps limit how many steps a funnel kpi can have
+	MinFunnelSteps = 2
+	MaxFunnelSteps = 10
+)
+
+var (
+	ErrInvalidFunnel = ValidationError("Funnels need between 2 and 10 steps and a funnelWindowDays that isn't negative.")
+	ErrNotFunnel     = ValidationError("The kpi is not a funnel.")
+)
+
+// FunnelSteps are the conditions tracks must meet, in order, for a visitor to
+// complete a funnel kpi
+type FunnelSteps []Condition
+
+// Value stores the steps as JSON
+func (s FunnelSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+// Scan loads steps stored as JSON
+func (s *FunnelSteps) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, s)
+	case string:
+		return json.Unmarshal([]byte(src), s)
+	}
+	return errors.New("funnel steps must be stored as JSON")
+}
+
+// FunnelTouch is a track that met one of a funnel's steps
+type FunnelTouch struct {
+	AnonymousID string    `db:"anonymous_id"`
+	Step        int       `db:"step"`
+	SentAt      time.Time `db:"sent_at"`
+}
+
+// FunnelRevenue is what a visitor's completed funnel was worth
+type FunnelRevenue struct {
+	AnonymousID string  `db:"anonymous_id"`
+	Revenue     float64 `db:"revenue"`
+}
+
+// Funnel is how many visitors reached each step of a funnel kpi and how the
+// visitors that completed it are attributed
+type Funnel struct {
+	Steps        []FunnelStep      `json:"steps"`
+	Attribution  []CreditAggregate `json:"attribution"`
+	ModelDetails interface{}       `json:"modelDetails,omitempty"`
+}
+
+// FunnelStep is how many visitors reached a step of a funnel, and the share of
+// the visitors that reached the step before that didn't
+type FunnelStep struct {
+	Step        Condition `json:"step"`
+	Visitors    int64     `json:"visitors"`
+	DropOffRate float64   `json:"dropOffRate"`
+}
+
+// funnelProgress is how far a visitor got through a funnel
+type funnelProgress struct {
+	reached     int
+	completedAt time.Time
+	revenue     float64 // what completing the funnel was worth
+}
+
+// findFunnelProgress works out how many steps each visitor reached in order,
+// starting from any of their first step touches, with every step within the
+// window of the first. touches are ordered by visitor and then sent_at.
+func findFunnelProgress(steps int, touches []FunnelTouch, window time.Duration) map[string]funnelProgress {
+	progress := map[string]funnelProgress{}
+
+	for start := 0; start < len(touches); {
+		end := start
+		for end < len(touches) && touches[end].AnonymousID == touches[start].AnonymousID {
+			end++
+		}
+		visitor := touches[start:end]
+
+		best := funnelProgress{}
+		for i, first := range visitor {
+			if first.Step != 0 {
+				continue
+			}
+			current := funnelProgress{reached: 1, completedAt: first.SentAt}
+			for _, t := range visitor[i+1:] {
+				if current.reached == steps {
+					break
+				}
+				if window > 0 && t.SentAt.Sub(first.SentAt) > window {
+					break
+				}
+				if t.Step == current.reached && t.SentAt.After(current.completedAt) {
+					current.reached++
+					current.completedAt = t.SentAt
+				}
+			}
+			if current.reached > best.reached {
+				best = current
+			}
+			if best.reached == steps {
+				break
+			}
+		}
+		if best.reached > 0 {
+			if best.reached < steps {
+				best.completedAt = time.Time{}
+			}
+			progress[touches[start].AnonymousID] = best
+		}
+
+		start = end
+	}
+
+	return progress
+}
+
+// countFunnelSteps returns how many visitors reached each step and how many
+// dropped off since the step before
+func countFunnelSteps(kpi Kpi, progress map[string]funnelProgress) []FunnelStep {
+	result := make([]FunnelStep, len(kpi.Steps))
+	for _, p := range progress {
+		for i := 0; i < p.reached; i++ {
+			result[i].Visitors++
+		}
+	}
+	for i := range result {
+		result[i].Step = kpi.Steps[i]
+		if i > 0 && result[i-1].Visitors > 0 {
+			result[i].DropOffRate = 1 - float64(result[i].Visitors)/float64(result[i-1].Visitors)
+		}
+	}
+	return result
+}
+
+// funnelJourneys splits touches, ordered by visitor and then sent_at, into
+// the touches before each completed funnel within the kpi's lookback window
+// and every funnel visitor's journey for data-driven models to learn from
+func funnelJourneys(kpi Kpi, progress map[string]funnelProgress, touches []JourneyTouch) ([]JourneyTouch, []JourneyTouch) {
+	lookback := time.Duration(kpi.LookbackDays) * 24 * time.Hour
+	var conversions, visitors []JourneyTouch
+
+	for _, t := range touches {
+		p, ok := progress[t.AnonymousID]
+		if !ok {
+			continue
+		}
+		if p.completedAt.IsZero() {
+			visitors = append(visitors, t)
+			continue
+		}
+		if !t.SentAt.Before(p.completedAt) || (lookback > 0 && p.completedAt.Sub(t.SentAt) > lookback) {
+			continue
+		}
+		t.ConversionAt = p.completedAt
+		t.Converted = true
+		t.Revenue = p.revenue
+		conversions = append(conversions, t)
+		visitors = append(visitors, t)
+	}
+
+	return conversions, visitors
+}
+
+// completedFunnels returns the visitors that completed the funnel and when they did
+func completedFunnels(progress map[string]funnelProgress) ([]string, []time.Time) {
+	var anonymousIDs []string
+	var completedAt []time.Time
+	for anonymousID, p := range progress {
+		if !p.completedAt.IsZero() {
+			anonymousIDs = append(anonymousIDs, anonymousID)
+			completedAt = append(completedAt, p.completedAt)
+		}
+	}
+	return anonymousIDs, completedAt
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFindFunnelProgress(t *testing.T) {
+	touches := []FunnelTouch{
+		{AnonymousID: "a", Step: 0, SentAt: day1},
+		{AnonymousID: "a", Step: 2, SentAt: day1.Add(time.Hour)},
+		{AnonymousID: "a", Step: 1, SentAt: day2},
+		{AnonymousID: "a", Step: 2, SentAt: day3},
+		{AnonymousID: "b", Step: 0, SentAt: day1},
+		{AnonymousID: "b", Step: 1, SentAt: day3},
+		{AnonymousID: "c", Step: 1, SentAt: day1},
+	}
+
+	t.Run("findFunnelProgress follows steps in order", func(t *testing.T) {
+		progress := findFunnelProgress(3, touches, 0)
+
+		if p := progress["a"]; p.reached != 3 || !p.completedAt.Equal(day3) {
+			t.Errorf("findFunnelProgress returned wrong progress: got %v want 3 steps at %v",
+				p, day3)
+		}
+		if p := progress["b"]; p.reached != 2 || !p.completedAt.IsZero() {
+			t.Errorf("findFunnelProgress returned wrong progress: got %v want 2 steps", p)
+		}
+		if _, ok := progress["c"]; ok {
+			t.Error("findFunnelProgress returned progress for a visitor that never started")
+		}
+	})
+
+	t.Run("findFunnelProgress leaves out steps after the window", func(t *testing.T) {
+		progress := findFunnelProgress(3, touches, 36*time.Hour)
+
+		if p := progress["a"]; p.reached != 2 {
+			t.Errorf("findFunnelProgress returned wrong progress: got %v want 2 steps", p)
+		}
+	})
+}
+
+func TestCountFunnelSteps(t *testing.T) {
+	kpi := Kpi{Steps: FunnelSteps{{}, {}, {}}}
+	progress := map[string]funnelProgress{
+		"a": {reached: 3},
+		"b": {reached: 2},
+		"c": {reached: 1},
+		"d": {reached: 1},
+	}
+
+	steps := countFunnelSteps(kpi, progress)
+
+	expected := []int64{4, 2, 1}
+	for i, step := range steps {
+		if step.Visitors != expected[i] {
+			t.Errorf("countFunnelSteps returned wrong visitors for step %v: got %v want %v",
+				i, step.Visitors, expected[i])
+		}
+	}
+	if math.Abs(steps[1].DropOffRate-0.5) > 1e-9 {
+		t.Errorf("countFunnelSteps returned wrong drop off rate: got %v want %v",
+			steps[1].DropOffRate, 0.5)
+	}
+}
+
+func TestFunnelJourneys(t *testing.T) {
+	progress := map[string]funnelProgress{
+		"a": {reached: 2, completedAt: day2, revenue: 50},
+		"b": {reached: 1},
+	}
+	touches := []JourneyTouch{
+		{AnonymousID: "a", Value: "Blog", SentAt: day1},
+		{AnonymousID: "a", Value: "Ads", SentAt: day3},
+		{AnonymousID: "b", Value: "Blog", SentAt: day1},
+	}
+
+	conversions, visitors := funnelJourneys(Kpi{}, progress, touches)
+
+	if len(conversions) != 1 || conversions[0].Revenue != 50 || !conversions[0].ConversionAt.Equal(day2) {
+		t.Errorf("funnelJourneys returned wrong conversions: got %+v want the blog touch worth 50", conversions)
+	}
+	if len(visitors) != 2 {
+		t.Errorf("funnelJourneys returned wrong number of visitor touches: got %v want %v",
+			len(visitors), 2)
+	}
+}
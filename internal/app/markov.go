@@ -0,0 +1,131 @@
+package app
+
+import "math"
+
+const (
+	markovStartState      = "(start)"
+	markovConversionState = "(conversion)"
+	markovNullState       = "(null)"
+	// Absorption probabilities are found by iterating until they stop changing
+	markovMaxIterations = 1000
+	markovTolerance     = 1e-9
+)
+
+// MarkovChain is what the markov model learnt from every visitor's journey
+type MarkovChain struct {
+	// TransitionMatrix is the probability of moving from one state to another,
+	// where states are channels plus (start), (conversion) and (null)
+	TransitionMatrix map[string]map[string]float64 `json:"transitionMatrix"`
+	// RemovalEffects is how much of the conversion probability would be lost
+	// if a channel was removed from every journey
+	RemovalEffects map[string]float64 `json:"removalEffects"`
+	ConversionRate float64            `json:"conversionRate"`
+}
+
+// markovModel builds a markov chain from the visitors' journeys and credits
+// each conversion's touches in proportion to their channel's removal effect
+func markovModel(visitors []journey) (attributionModel, interface{}) {
+	chain := newMarkovChain(visitors)
+
+	model := func(kpi Kpi, j journey) []float64 {
+		credit := make([]float64, len(j))
+		total := 0.0
+		for i, t := range j {
+			credit[i] = chain.RemovalEffects[t.Value]
+			total += credit[i]
+		}
+		if total == 0 {
+			return linear(kpi, j)
+		}
+		for i := range credit {
+			credit[i] /= total
+		}
+		return credit
+	}
+
+	return model, chain
+}
+
+func newMarkovChain(visitors []journey) MarkovChain {
+	counts := map[string]map[string]float64{}
+	addTransition := func(from, to string) {
+		if counts[from] == nil {
+			counts[from] = map[string]float64{}
+		}
+		counts[from][to]++
+	}
+
+	for _, j := range visitors {
+		from := markovStartState
+		for _, t := range j {
+			addTransition(from, t.Value)
+			from = t.Value
+		}
+		if j[0].Converted {
+			addTransition(from, markovConversionState)
+		} else {
+			addTransition(from, markovNullState)
+		}
+	}
+
+	// Normalize counts into probabilities
+	matrix := map[string]map[string]float64{}
+	for from, tos := range counts {
+		total := 0.0
+		for _, count := range tos {
+			total += count
+		}
+		matrix[from] = map[string]float64{}
+		for to, count := range tos {
+			matrix[from][to] = count / total
+		}
+	}
+
+	chain := MarkovChain{
+		TransitionMatrix: matrix,
+		RemovalEffects:   map[string]float64{},
+		ConversionRate:   conversionProbability(matrix, ""),
+	}
+	for state := range matrix {
+		if state == markovStartState {
+			continue
+		}
+		if chain.ConversionRate == 0 {
+			chain.RemovalEffects[state] = 0
+			continue
+		}
+		chain.RemovalEffects[state] = 1 - conversionProbability(matrix, state)/chain.ConversionRate
+	}
+
+	return chain
+}
+
+// conversionProbability returns the probability of reaching (conversion) from
+// (start). If removed is set, every transition into that state goes to (null)
+// instead.
+func conversionProbability(matrix map[string]map[string]float64, removed string) float64 {
+	probabilities := map[string]float64{markovConversionState: 1}
+
+	for iteration := 0; iteration < markovMaxIterations; iteration++ {
+		delta := 0.0
+		for from, tos := range matrix {
+			if from == removed {
+				continue
+			}
+			p := 0.0
+			for to, transition := range tos {
+				if to == removed {
+					continue
+				}
+				p += transition * probabilities[to]
+			}
+			delta = math.Max(delta, math.Abs(p-probabilities[from]))
+			probabilities[from] = p
+		}
+		if delta < markovTolerance {
+			break
+		}
+	}
+
+	return probabilities[markovStartState]
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewMarkovChain(t *testing.T) {
+	visitors := []journey{
+		{{AnonymousID: "a", Value: "Paid Search", Converted: true}},
+		{{AnonymousID: "b", Value: "Paid Search", Converted: true}, {AnonymousID: "b", Value: "Social", Converted: true}},
+		{{AnonymousID: "c", Value: "Social"}},
+	}
+
+	chain := newMarkovChain(visitors)
+
+	t.Run("newMarkovChain builds transition probabilities from journeys", func(t *testing.T) {
+		expected := 2.0 / 3
+		if p := chain.TransitionMatrix[markovStartState]["Paid Search"]; math.Abs(p-expected) > 1e-9 {
+			t.Errorf("newMarkovChain returned wrong transition probability: got %v want %v",
+				p, expected)
+		}
+	})
+
+	t.Run("newMarkovChain finds the conversion rate and removal effects", func(t *testing.T) {
+		expected := map[string]float64{
+			"Paid Search": 0.75,
+			"Social":      0.5,
+		}
+
+		if math.Abs(chain.ConversionRate-2.0/3) > 1e-6 {
+			t.Errorf("newMarkovChain returned wrong conversion rate: got %v want %v",
+				chain.ConversionRate, 2.0/3)
+		}
+		for channel, effect := range expected {
+			if math.Abs(chain.RemovalEffects[channel]-effect) > 1e-6 {
+				t.Errorf("newMarkovChain returned wrong removal effect for %v: got %v want %v",
+					channel, chain.RemovalEffects[channel], effect)
+			}
+		}
+	})
+}
@@ -3,84 +3,260 @@ package app
 import (
 	"errors"
 	"log"
+	"strings"
+	"time"
 )
 
 const (
-	DefaultModelIDValue = "first-touch"
+	FirstTouchModelID   = "first-touch"
+	LastTouchModelID    = "last-touch"
+	LinearModelID       = "linear"
+	TimeDecayModelID    = "time-decay"
+	UShapedModelID      = "u-shaped"
+	WShapedModelID      = "w-shaped"
+	MarkovModelID       = "markov"
+	ShapleyModelID      = "shapley"
+	WeightedModelID     = "weighted"
+	DefaultModelIDValue = FirstTouchModelID
+	// DefaultHalfLifeDays is used by the time-decay model when a kpi doesn't set one
+	DefaultHalfLifeDays = 7
+	// Default position weights used by the u-shaped and w-shaped models when a kpi doesn't set any
+	DefaultUShapedEdgeWeight     = 0.4
+	DefaultWShapedPositionWeight = 0.3
+	// DefaultDimension is the column conversions are attributed to when a kpi or weight doesn't say
+	DefaultDimension = "campaign_name"
+	// MaxTrackBatch is how many tracks can be stored at once
+	MaxTrackBatch = 500
+	// MaxTimeseriesPoints is how many periods a kpi timeseries can have
+	MaxTimeseriesPoints = 5000
+	// MaxDimensions is how many columns conversions can be attributed to at once
+	MaxDimensions = 3
+	// DimensionSeparator separates the value of each dimension when values are loaded for several
+	DimensionSeparator = "\x1f"
+	// dimensionLabelSeparator separates the value of each dimension in readable values
+	dimensionLabelSeparator = " / "
 )
 
+var (
+	ErrUnknownModelID         = ValidationError("The modelId you sent is not a supported attribution model.")
+	ErrInvalidPositionWeights = ValidationError("Position weights must be between 0 and 1 and add up to at most 1.")
+	ErrMissingLeadPattern     = ValidationError("The w-shaped model needs a leadColumn and leadValue.")
+	ErrNegativeWeight         = ValidationError("Weights can't be negative.")
+	ErrUnknownDimension       = ValidationError("The column you sent can't be used to attribute conversions.")
+	ErrTooManyDimensions      = ValidationError("Conversions can be attributed to at most 3 different columns.")
+	ErrWeightedDimensions     = ValidationError("The weighted model can only attribute conversions to one column.")
+	ErrUnknownColumn          = ValidationError("The column you sent is not a track column that can be matched on.")
+	ErrNegativeLookback       = ValidationError("The lookbackDays can't be negative.")
+	ErrNegativeHalfLife       = ValidationError("The halfLifeDays can't be negative.")
+	ErrUnknownGranularity     = ValidationError("The granularity must be day, week or month.")
+	ErrTooManyPoints          = ValidationError("Timeseries can have at most 5000 periods, use a shorter date range or a larger granularity.")
+	ErrTooManyTracks          = ValidationError("At most 500 tracks can be sent in one batch.")
+	ErrMissingUserID          = ValidationError("Billing events need a userId.")
+	ErrKpiNotFound            = errors.New("No kpi was found with that id")
+	ErrUnknownSecret          = errors.New("No user was found for that secret")
+)
+
+// DefaultComparisonModelIDs are compared when no models are asked for
+var DefaultComparisonModelIDs = []string{FirstTouchModelID, LastTouchModelID, LinearModelID}
+
+// dimensionColumns are the track columns that conversions can be attributed to
+var dimensionColumns = map[string]bool{
+	"campaign_source":  true,
+	"campaign_medium":  true,
+	"campaign_name":    true,
+	"campaign_content": true,
+	"page_referrer":    true,
+	"page_path":        true,
+}
+
+// ValidationError is returned when a request is rejected because of the data
+// that was sent, rather than something going wrong on our end
+type ValidationError string
+
+func (e ValidationError) Error() string {
+	return string(e)
+}
+
 type Service struct {
-	tracksDAO TracksDAO
-	kpisDAO   KpisDAO
-	usersDAO  UsersDAO
+	tracksDAO        TracksDAO
+	kpisDAO          KpisDAO
+	usersDAO         UsersDAO
+	weightsDAO       WeightsDAO
+	billingEventsDAO BillingEventsDAO
 }
 
 // NewService returns new service object
-func NewService(tracksDAO TracksDAO, kpisDAO KpisDAO, usersDAO UsersDAO) Service {
+func NewService(tracksDAO TracksDAO, kpisDAO KpisDAO, usersDAO UsersDAO, weightsDAO WeightsDAO, billingEventsDAO BillingEventsDAO) Service {
 	return Service{
-		tracksDAO: tracksDAO,
-		kpisDAO:   kpisDAO,
-		usersDAO:  usersDAO,
+		tracksDAO:        tracksDAO,
+		kpisDAO:          kpisDAO,
+		usersDAO:         usersDAO,
+		weightsDAO:       weightsDAO,
+		billingEventsDAO: billingEventsDAO,
 	}
 }
 
 func (s Service) NewTrack(t Track, ownerSecret string) (int64, error) {
-	users, err := s.usersDAO.FindBySecret(ownerSecret)
+	user, err := s.findOwner(ownerSecret)
+	if err != nil {
+		return 0, err
+	}
+
+	t.OwnerID = user.UUID
+
+	return s.tracksDAO.Store(t)
+}
+
+// NewTracks stores a batch of tracks for the owner with the secret
+func (s Service) NewTracks(tracks []Track, ownerSecret string) ([]int64, error) {
+	if len(tracks) > MaxTrackBatch {
+		return nil, ErrTooManyTracks
+	}
+
+	user, err := s.findOwner(ownerSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tracks {
+		tracks[i].OwnerID = user.UUID
+	}
+
+	return s.tracksDAO.StoreBatch(tracks)
+}
+
+// NewBillingEvent stores a payment made by one of the owner's users
+func (s Service) NewBillingEvent(e BillingEvent, ownerSecret string) (int64, error) {
+	if e.UserID == "" {
+		return 0, ErrMissingUserID
+	}
+
+	user, err := s.findOwner(ownerSecret)
 	if err != nil {
 		return 0, err
 	}
 
-	// TODO: Make this print a 4xx error instead of flowing up to a 500
-	// this will probably involve creating a custom error object
+	e.OwnerID = user.UUID
+	if e.SentAt.IsZero() {
+		e.SentAt = time.Now()
+	}
+
+	return s.billingEventsDAO.Store(e)
+}
+
+// findOwner returns the user with the secret
+func (s Service) findOwner(ownerSecret string) (User, error) {
+	users, err := s.usersDAO.FindBySecret(ownerSecret)
+	if err != nil {
+		return User{}, err
+	}
+
 	if len(users) == 0 {
-		return 0, errors.New("No user was found for that secret")
+		return User{}, ErrUnknownSecret
 	}
 
 	if len(users) > 1 {
 		errStr := "Found multiple users for one secret key"
 		// Note: This error is serious af... idk how this could happen
 		log.Println(errStr)
-		return 0, errors.New(errStr)
+		return User{}, errors.New(errStr)
 	}
-	user := users[0]
 
-	t.OwnerID = user.UUID
-
-	return s.tracksDAO.Store(t)
+	return users[0], nil
 }
 
 func (s Service) NewKpi(kpi Kpi) (int64, error) {
-	if kpi.ModelID == "" {
-		kpi.ModelID = DefaultModelIDValue
+	setKpiDefaults(&kpi)
+	if err := validateKpi(kpi); err != nil {
+		return 0, err
+	}
+	if err := s.checkRegexes(kpi); err != nil {
+		return 0, err
 	}
 	return s.kpisDAO.Store(kpi)
 }
 
 func (s Service) UpdateKpi(kpi Kpi) error {
+	setKpiDefaults(&kpi)
+	if err := validateKpi(kpi); err != nil {
+		return err
+	}
+	if err := s.checkRegexes(kpi); err != nil {
+		return err
+	}
 	return s.kpisDAO.Update(kpi)
 }
 
+// checkRegexes has postgres, which runs the kpi's regexes, check that they are valid
+func (s Service) checkRegexes(kpi Kpi) error {
+	conditions := append([]Condition{}, kpi.Steps...)
+	if kpi.Condition != nil {
+		conditions = append(conditions, *kpi.Condition)
+	}
+	for _, pattern := range conditionRegexes(conditions...) {
+		if err := s.tracksDAO.CheckRegex(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findValidKpi returns the owner's kpi with its defaults filled in, or the
+// ValidationError of a stored kpi whose settings are no longer valid
+func (s Service) findValidKpi(id int64, ownerID string) (Kpi, error) {
+	kpi, err := s.kpisDAO.FindByID(id, ownerID)
+	if err != nil {
+		return Kpi{}, err
+	}
+	setKpiDefaults(&kpi)
+	if err := validateKpi(kpi); err != nil {
+		return Kpi{}, err
+	}
+	return kpi, nil
+}
+
+// setKpiDefaults fills in the settings the kpi left empty
+func setKpiDefaults(kpi *Kpi) {
+	if kpi.ModelID == "" {
+		kpi.ModelID = DefaultModelIDValue
+	}
+	if kpi.Dimension == "" {
+		kpi.Dimension = DefaultDimension
+	}
+	if kpi.TargetPeriod == "" {
+		kpi.TargetPeriod = DefaultTargetPeriod
+	}
+}
+
 func (s Service) DeleteKpi(kpi Kpi) (int64, error) {
 	return s.kpisDAO.Delete(kpi.ID, kpi.OwnerID)
 }
 
-func (s Service) GetKpisForUser(ownerID string) ([]Kpi, error) {
+// GetKpisForUser returns the owner's kpis. If includeAggregates is set, each kpi's conversions
+// in the date range are attributed to its dimensions, or to dimension if it is set.
+func (s Service) GetKpisForUser(ownerID, dimension string, dateRange DateRange, includeAggregates bool) ([]Kpi, error) {
+	if _, err := parseDimensions(dimension); dimension != "" && err != nil {
+		return nil, err
+	}
+
 	kpis, err := s.kpisDAO.FindByOwnerID(ownerID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get aggregates for the kpi
-	for i, kpi := range kpis {
-		// Get aggregate data
-		aggregate, err := s.tracksDAO.GetNormalizedJourneyAggregate(kpi.OwnerID, "campaign_name", kpi.PatternMatchColumnName, kpi.PatternMatchRowValue)
-		if err != nil {
-			return nil, err
+	// Get aggregates for the kpis
+	if includeAggregates {
+		for i := range kpis {
+			err := s.addAggregates(&kpis[i], dimension, dateRange)
+			if _, ok := err.(ValidationError); ok {
+				// One kpi with invalid settings shouldn't hide the others
+				kpis[i].Error = err.Error()
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
 		}
-		if aggregate == nil {
-			aggregate = []PosAggregate{}
-		}
-		kpis[i].CampaignNameJourneyAggregate = aggregate
 	}
 
 	// Format
@@ -90,3 +266,504 @@ func (s Service) GetKpisForUser(ownerID string) ([]Kpi, error) {
 
 	return kpis, nil
 }
+
+// GetKpi returns one of the owner's kpis with its conversions in the date range attributed
+// to its dimensions, or to dimension if it is set
+func (s Service) GetKpi(id int64, ownerID, dimension string, dateRange DateRange) (Kpi, error) {
+	if _, err := parseDimensions(dimension); dimension != "" && err != nil {
+		return Kpi{}, err
+	}
+
+	kpi, err := s.kpisDAO.FindByID(id, ownerID)
+	if err != nil {
+		return Kpi{}, err
+	}
+
+	err = s.addAggregates(&kpi, dimension, dateRange)
+	if _, ok := err.(ValidationError); ok {
+		kpi.Error = err.Error()
+		return kpi, nil
+	}
+	if err != nil {
+		return Kpi{}, err
+	}
+
+	return kpi, nil
+}
+
+// addAggregates adds the kpi's journey aggregate, attribution and progress against its target.
+// Kpis stored before their settings were validated return a ValidationError instead.
+func (s Service) addAggregates(kpi *Kpi, dimension string, dateRange DateRange) error {
+	setKpiDefaults(kpi)
+	if err := validateKpi(*kpi); err != nil {
+		return err
+	}
+
+	kpi.Dimension = kpiDimension(*kpi, dimension)
+	dimensions, err := parseDimensions(kpi.Dimension)
+	if err != nil {
+		return err
+	}
+
+	// Get aggregate data
+	aggregate, err := s.tracksDAO.GetNormalizedJourneyAggregate(*kpi, dimensions, dateRange)
+	if err != nil {
+		return err
+	}
+	if aggregate == nil {
+		aggregate = []PosAggregate{}
+	}
+	for j := range aggregate {
+		aggregate[j].Value, aggregate[j].Values = splitDimensionValue(aggregate[j].Value)
+	}
+	kpi.JourneyAggregate = &aggregate
+
+	// Attribute conversions using the kpi's model
+	attribution, details, err := s.getAttribution(*kpi, dimensions, dateRange)
+	if err != nil {
+		return err
+	}
+	if attribution == nil {
+		attribution = []CreditAggregate{}
+	}
+	kpi.Attribution = &attribution
+	kpi.ModelDetails = details
+
+	// Compare this period's conversions with the target
+	if kpi.Target > 0 {
+		progress, err := s.getTargetProgress(*kpi, dateRange.Location, time.Now())
+		if err != nil {
+			return err
+		}
+		kpi.Progress = &progress
+	}
+
+	return nil
+}
+
+// getTargetProgress counts the kpi's conversions so far in its current target period, in
+// the location, and compares them with its target
+func (s Service) getTargetProgress(kpi Kpi, location *time.Location, now time.Time) (TargetProgress, error) {
+	if location == nil {
+		location = time.UTC
+	}
+	now = now.In(location)
+	start, end := currentTargetPeriod(kpi.TargetPeriod, now)
+
+	counts, err := s.tracksDAO.GetConversionTimeseries(kpi, DayGranularity, DateRange{From: start, To: now, Location: location})
+	if err != nil {
+		return TargetProgress{}, err
+	}
+	conversions := int64(0)
+	for _, c := range counts {
+		conversions += c.Conversions
+	}
+
+	return targetProgress(kpi, conversions, start, end, now), nil
+}
+
+// kpiDimension returns the columns to attribute the kpi's conversions to
+func kpiDimension(kpi Kpi, dimension string) string {
+	if dimension != "" {
+		return dimension
+	}
+	if kpi.Dimension != "" {
+		return kpi.Dimension
+	}
+	return DefaultDimension
+}
+
+// parseDimensions splits comma separated dimensions into columns, making
+// sure each one can be attributed to
+func parseDimensions(dimension string) ([]string, error) {
+	dimensions := strings.Split(dimension, ",")
+	if len(dimensions) > MaxDimensions {
+		return nil, ErrTooManyDimensions
+	}
+
+	seen := map[string]bool{}
+	for i, d := range dimensions {
+		d = strings.TrimSpace(d)
+		if !dimensionColumns[d] || seen[d] {
+			return nil, ErrUnknownDimension
+		}
+		seen[d] = true
+		dimensions[i] = d
+	}
+
+	return dimensions, nil
+}
+
+// splitDimensionValue splits a value loaded for several dimensions into a
+// readable value and the value of each dimension
+func splitDimensionValue(value string) (string, []string) {
+	values := strings.Split(value, DimensionSeparator)
+	return strings.Join(values, dimensionLabelSeparator), values
+}
+
+// getAttribution credits the kpi's conversions to values of the dimensions using the kpi's
+// model. Data-driven models also return what they learnt from every visitor's journey.
+func (s Service) getAttribution(kpi Kpi, dimensions []string, dateRange DateRange) ([]CreditAggregate, interface{}, error) {
+	model, details, err := s.loadModel(kpi, dimensions, dateRange)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	touches, err := s.tracksDAO.GetConversionJourneys(kpi, dimensions, dateRange)
+	if err != nil {
+		return nil, nil, err
+	}
+	prepareTouches(touches, dateRange)
+
+	return attribute(kpi, model, touches), details, nil
+}
+
+// loadModel returns the kpi's attribution model, first learning from visitor journeys
+// or loading the owner's weights if the model needs them
+func (s Service) loadModel(kpi Kpi, dimensions []string, dateRange DateRange) (attributionModel, interface{}, error) {
+	if model, ok := attributionModels[kpi.ModelID]; ok {
+		return model, nil, nil
+	}
+
+	if learn, ok := dataDrivenModels[kpi.ModelID]; ok {
+		visitors, err := s.tracksDAO.GetVisitorJourneys(kpi, dimensions, dateRange)
+		if err != nil {
+			return nil, nil, err
+		}
+		prepareTouches(visitors, dateRange)
+		model, details := learn(groupJourneys(visitors))
+		return model, details, nil
+	}
+
+	if kpi.ModelID == WeightedModelID {
+		if len(dimensions) > 1 {
+			return nil, nil, ErrWeightedDimensions
+		}
+		weights, err := s.weightsDAO.FindByOwnerID(kpi.OwnerID, dimensions[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		return weightedModel(weights), nil, nil
+	}
+
+	return nil, nil, ErrUnknownModelID
+}
+
+// prepareTouches splits the value of every touch into the value of each
+// dimension and moves its times into the date range's location
+func prepareTouches(touches []JourneyTouch, dateRange DateRange) {
+	for i := range touches {
+		touches[i].Value, touches[i].Values = splitDimensionValue(touches[i].Value)
+		if dateRange.Location != nil {
+			touches[i].SentAt = touches[i].SentAt.In(dateRange.Location)
+			touches[i].ConversionAt = touches[i].ConversionAt.In(dateRange.Location)
+		}
+	}
+}
+
+// CompareModels attributes one kpi's conversions in the date range with each
+// of the models so they can be compared side by side
+func (s Service) CompareModels(id int64, ownerID, dimension string, modelIDs []string, dateRange DateRange) ([]ModelAttribution, error) {
+	if _, err := parseDimensions(dimension); dimension != "" && err != nil {
+		return nil, err
+	}
+	if len(modelIDs) == 0 {
+		modelIDs = DefaultComparisonModelIDs
+	}
+	for _, modelID := range modelIDs {
+		if !modelExists(modelID) {
+			return nil, ErrUnknownModelID
+		}
+	}
+
+	kpi, err := s.findValidKpi(id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	dimensions, err := parseDimensions(kpiDimension(kpi, dimension))
+	if err != nil {
+		return nil, err
+	}
+
+	// Every model credits the same conversions
+	touches, err := s.tracksDAO.GetConversionJourneys(kpi, dimensions, dateRange)
+	if err != nil {
+		return nil, err
+	}
+	prepareTouches(touches, dateRange)
+
+	comparison := []ModelAttribution{}
+	for _, modelID := range modelIDs {
+		kpi.ModelID = modelID
+		model, details, err := s.loadModel(kpi, dimensions, dateRange)
+		if err != nil {
+			return nil, err
+		}
+
+		conversions := map[string]float64{}
+		revenue := map[string]float64{}
+		for _, aggregate := range attribute(kpi, model, touches) {
+			conversions[aggregate.Value] += aggregate.Conversions
+			revenue[aggregate.Value] += aggregate.Revenue
+		}
+
+		comparison = append(comparison, ModelAttribution{
+			ModelID:      modelID,
+			Conversions:  conversions,
+			Revenue:      revenue,
+			ModelDetails: details,
+		})
+	}
+
+	return comparison, nil
+}
+
+// GetKpiTimeseries returns one kpi's conversions in the date range per period,
+// along with the credit each value earned for the conversions in that period
+func (s Service) GetKpiTimeseries(id int64, ownerID, dimension, granularity string, dateRange DateRange) ([]TimeseriesPoint, error) {
+	if !granularities[granularity] {
+		return nil, ErrUnknownGranularity
+	}
+	if dateRange.Location == nil {
+		dateRange.Location = time.UTC
+	}
+	// Open ranges are checked once the periods with data are known
+	if !dateRange.From.IsZero() && !dateRange.To.IsZero() {
+		first, last := timeseriesBounds(time.Time{}, time.Time{}, granularity, dateRange)
+		if tooManyPeriods(first, last, granularity) {
+			return nil, ErrTooManyPoints
+		}
+	}
+
+	kpi, err := s.findValidKpi(id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	dimensions, err := parseDimensions(kpiDimension(kpi, dimension))
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.tracksDAO.GetConversionTimeseries(kpi, granularity, dateRange)
+	if err != nil {
+		return nil, err
+	}
+
+	model, _, err := s.loadModel(kpi, dimensions, dateRange)
+	if err != nil {
+		return nil, err
+	}
+	touches, err := s.tracksDAO.GetConversionJourneys(kpi, dimensions, dateRange)
+	if err != nil {
+		return nil, err
+	}
+	prepareTouches(touches, dateRange)
+
+	// Credit goes to the period of the conversion rather than of the touch
+	credits := attributeBy(kpi, model, touches, func(t JourneyTouch) time.Time {
+		return truncatePeriod(t.ConversionAt, granularity)
+	})
+
+	return fillTimeseries(counts, credits, granularity, dateRange)
+}
+
+// GetFunnel counts the visitors that reached each step of a funnel kpi in the date
+// range and attributes the visitors that completed it using the kpi's model
+func (s Service) GetFunnel(id int64, ownerID, dimension string, dateRange DateRange) (Funnel, error) {
+	kpi, err := s.findValidKpi(id, ownerID)
+	if err != nil {
+		return Funnel{}, err
+	}
+	if len(kpi.Steps) == 0 {
+		return Funnel{}, ErrNotFunnel
+	}
+
+	dimensions, err := parseDimensions(kpiDimension(kpi, dimension))
+	if err != nil {
+		return Funnel{}, err
+	}
+
+	steps, err := s.tracksDAO.GetFunnelTouches(kpi, dateRange)
+	if err != nil {
+		return Funnel{}, err
+	}
+	window := time.Duration(kpi.FunnelWindowDays) * 24 * time.Hour
+	progress := findFunnelProgress(len(kpi.Steps), steps, window)
+
+	funnel := Funnel{
+		Steps:       countFunnelSteps(kpi, progress),
+		Attribution: []CreditAggregate{},
+	}
+	if len(progress) == 0 {
+		return funnel, nil
+	}
+
+	if completed, completedAt := completedFunnels(progress); len(completed) > 0 {
+		revenues, err := s.tracksDAO.GetFunnelRevenues(kpi, completed, completedAt)
+		if err != nil {
+			return Funnel{}, err
+		}
+		for _, r := range revenues {
+			p := progress[r.AnonymousID]
+			p.revenue = r.Revenue
+			progress[r.AnonymousID] = p
+		}
+	}
+
+	anonymousIDs := make([]string, 0, len(progress))
+	for anonymousID := range progress {
+		anonymousIDs = append(anonymousIDs, anonymousID)
+	}
+	touches, err := s.tracksDAO.GetTouchesForVisitors(kpi.OwnerID, dimensions, anonymousIDs)
+	if err != nil {
+		return Funnel{}, err
+	}
+	prepareTouches(touches, dateRange)
+	conversions, visitors := funnelJourneys(kpi, progress, touches)
+
+	// Data-driven models learn from the funnel's visitors rather than the
+	// kpi's conversions
+	var model attributionModel
+	if learn, ok := dataDrivenModels[kpi.ModelID]; ok {
+		model, funnel.ModelDetails = learn(groupJourneys(visitors))
+	} else if model, _, err = s.loadModel(kpi, dimensions, dateRange); err != nil {
+		return Funnel{}, err
+	}
+	funnel.Attribution = attribute(kpi, model, conversions)
+
+	return funnel, nil
+}
+
+// validateKpi checks that the kpi only matches on track columns and that its
+// model exists and has the settings it needs
+func validateKpi(kpi Kpi) error {
+	if err := validateCondition(kpi.MatchCondition(), 0); err != nil {
+		return err
+	}
+	if kpi.Steps != nil {
+		if len(kpi.Steps) < MinFunnelSteps || len(kpi.Steps) > MaxFunnelSteps || kpi.FunnelWindowDays < 0 {
+			return ErrInvalidFunnel
+		}
+		for _, step := range kpi.Steps {
+			if err := validateCondition(step, 0); err != nil {
+				return err
+			}
+		}
+	}
+	if kpi.LeadPatternMatchColumnName != "" && !IsTrackColumn(kpi.LeadPatternMatchColumnName) {
+		return ErrUnknownColumn
+	}
+	if !modelExists(kpi.ModelID) {
+		return ErrUnknownModelID
+	}
+	if kpi.LookbackDays < 0 {
+		return ErrNegativeLookback
+	}
+	if kpi.HalfLifeDays < 0 {
+		return ErrNegativeHalfLife
+	}
+	if kpi.Target < 0 {
+		return ErrNegativeTarget
+	}
+	if !targetPeriods[kpi.TargetPeriod] {
+		return ErrUnknownTargetPeriod
+	}
+	dimensions, err := parseDimensions(kpi.Dimension)
+	if err != nil {
+		return err
+	}
+	if kpi.ModelID == WeightedModelID && len(dimensions) > 1 {
+		return ErrWeightedDimensions
+	}
+
+	weights := []float64{kpi.FirstTouchWeight, kpi.LeadTouchWeight, kpi.LastTouchWeight}
+	total := 0.0
+	for _, w := range weights {
+		if w < 0 || w > 1 {
+			return ErrInvalidPositionWeights
+		}
+		total += w
+	}
+	if total > 1 {
+		return ErrInvalidPositionWeights
+	}
+
+	if kpi.ModelID == WShapedModelID && (kpi.LeadPatternMatchColumnName == "" || kpi.LeadPatternMatchRowValue == "") {
+		return ErrMissingLeadPattern
+	}
+
+	return nil
+}
+
+func (s Service) NewWeight(weight Weight) (int64, error) {
+	if weight.ColumnName == "" {
+		weight.ColumnName = DefaultDimension
+	}
+	if !dimensionColumns[weight.ColumnName] {
+		return 0, ErrUnknownDimension
+	}
+	if weight.Weight < 0 {
+		return 0, ErrNegativeWeight
+	}
+	return s.weightsDAO.Store(weight)
+}
+
+func (s Service) UpdateWeight(weight Weight) error {
+	if weight.ColumnName == "" {
+		weight.ColumnName = DefaultDimension
+	}
+	if !dimensionColumns[weight.ColumnName] {
+		return ErrUnknownDimension
+	}
+	if weight.Weight < 0 {
+		return ErrNegativeWeight
+	}
+	return s.weightsDAO.Update(weight)
+}
+
+func (s Service) DeleteWeight(weight Weight) (int64, error) {
+	return s.weightsDAO.Delete(weight.ID, weight.OwnerID)
+}
+
+func (s Service) GetWeightsForUser(ownerID, columnName string) ([]Weight, error) {
+	if columnName == "" {
+		columnName = DefaultDimension
+	}
+	if !dimensionColumns[columnName] {
+		return nil, ErrUnknownDimension
+	}
+	weights, err := s.weightsDAO.FindByOwnerID(ownerID, columnName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Format
+	if weights == nil {
+		weights = []Weight{}
+	}
+
+	return weights, nil
+}
+
+// GetUnweightedValues returns values of the column that have been tracked but don't have a weight yet
+func (s Service) GetUnweightedValues(ownerID, columnName string) ([]string, error) {
+	if columnName == "" {
+		columnName = DefaultDimension
+	}
+	if !dimensionColumns[columnName] {
+		return nil, ErrUnknownDimension
+	}
+	values, err := s.weightsDAO.FindUnweightedValues(ownerID, columnName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Format
+	if values == nil {
+		values = []string{}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDimensions(t *testing.T) {
+
+	t.Run("parseDimensions splits comma separated columns", func(t *testing.T) {
+		expected := []string{"campaign_source", "campaign_medium"}
+
+		dimensions, err := parseDimensions("campaign_source, campaign_medium")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dimensions, expected) {
+			t.Errorf("parseDimensions returned unexpected dimensions: got %v want %v",
+				dimensions, expected)
+		}
+	})
+
+	t.Run("parseDimensions rejects columns that aren't dimensions", func(t *testing.T) {
+		_, err := parseDimensions("campaign_name,owner_id")
+
+		if err != ErrUnknownDimension {
+			t.Errorf("parseDimensions returned wrong error: got %v want %v",
+				err, ErrUnknownDimension)
+		}
+	})
+
+	t.Run("parseDimensions rejects too many columns", func(t *testing.T) {
+		_, err := parseDimensions("campaign_source,campaign_medium,campaign_name,campaign_content")
+
+		if err != ErrTooManyDimensions {
+			t.Errorf("parseDimensions returned wrong error: got %v want %v",
+				err, ErrTooManyDimensions)
+		}
+	})
+}
+
+func TestSplitDimensionValue(t *testing.T) {
+
+	t.Run("splitDimensionValue returns a readable value and each dimension's value", func(t *testing.T) {
+		value, values := splitDimensionValue("Paid Search" + DimensionSeparator + "Banner")
+
+		if value != "Paid Search / Banner" {
+			t.Errorf("splitDimensionValue returned unexpected value: got %v want %v",
+				value, "Paid Search / Banner")
+		}
+		if !reflect.DeepEqual(values, []string{"Paid Search", "Banner"}) {
+			t.Errorf("splitDimensionValue returned unexpected values: got %v", values)
+		}
+	})
+}
+
+// fakeKpisDAO returns kpis without a database. Methods it doesn't override panic.
+type fakeKpisDAO struct {
+	KpisDAO
+	kpis []Kpi
+}
+
+func (dao fakeKpisDAO) FindByOwnerID(ownerID string) ([]Kpi, error) {
+	return dao.kpis, nil
+}
+
+func (dao fakeKpisDAO) FindByID(id int64, ownerID string) (Kpi, error) {
+	for _, kpi := range dao.kpis {
+		if kpi.ID == id {
+			return kpi, nil
+		}
+	}
+	return Kpi{}, ErrKpiNotFound
+}
+
+func TestGetKpisForUser(t *testing.T) {
+
+	t.Run("GetKpisForUser reports kpis with invalid settings instead of failing", func(t *testing.T) {
+		kpisDAO := fakeKpisDAO{kpis: []Kpi{
+			{ID: 1, ModelID: "last-click", PatternMatchColumnName: "event", PatternMatchRowValue: "signup"},
+		}}
+		s := NewService(nil, kpisDAO, nil, nil, nil)
+
+		kpis, err := s.GetKpisForUser("owner", "", DateRange{}, true)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(kpis) != 1 || kpis[0].Error != ErrUnknownModelID.Error() {
+			t.Errorf("GetKpisForUser returned unexpected kpis: got %+v want one with error %v",
+				kpis, ErrUnknownModelID)
+		}
+	})
+}
+
+// fakeUsersDAO finds the same user for every secret
+type fakeUsersDAO struct {
+	user User
+}
+
+func (dao fakeUsersDAO) FindBySecret(secret string) ([]User, error) {
+	return []User{dao.user}, nil
+}
+
+// fakeBillingEventsDAO keeps the events it stores
+type fakeBillingEventsDAO struct {
+	events *[]BillingEvent
+}
+
+func (dao fakeBillingEventsDAO) Store(e BillingEvent) (int64, error) {
+	*dao.events = append(*dao.events, e)
+	return int64(len(*dao.events)), nil
+}
+
+func TestNewBillingEvent(t *testing.T) {
+
+	t.Run("NewBillingEvent sets sentAt to now when it is missing", func(t *testing.T) {
+		events := []BillingEvent{}
+		s := NewService(nil, nil, fakeUsersDAO{User{UUID: "owner"}}, nil, fakeBillingEventsDAO{&events})
+		before := time.Now()
+
+		_, err := s.NewBillingEvent(BillingEvent{UserID: "user", Amount: 10}, "secret")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].SentAt.Before(before) || events[0].OwnerID != "owner" {
+			t.Errorf("NewBillingEvent stored unexpected events: got %+v want one sent after %v",
+				events, before)
+		}
+	})
+}
+
+func TestValidateKpi(t *testing.T) {
+	valid := Kpi{
+		ModelID:                TimeDecayModelID,
+		PatternMatchColumnName: "event",
+		PatternMatchRowValue:   "signup",
+	}
+	setKpiDefaults(&valid)
+	negativeHalfLife := valid
+	negativeHalfLife.HalfLifeDays = -1
+
+	tests := []struct {
+		name     string
+		kpi      Kpi
+		expected error
+	}{
+		{"a kpi with defaults is valid", valid, nil},
+		{"negative half-lives are invalid", negativeHalfLife, ErrNegativeHalfLife},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateKpi(test.kpi); err != test.expected {
+				t.Errorf("validateKpi returned wrong error: got %v want %v",
+					err, test.expected)
+			}
+		})
+	}
+}
+
+func TestFindValidKpi(t *testing.T) {
+	kpisDAO := fakeKpisDAO{kpis: []Kpi{
+		{ID: 1, PatternMatchColumnName: "event", PatternMatchRowValue: "signup"},
+		{ID: 2, PatternMatchColumnName: "sent_at", PatternMatchRowValue: "signup"},
+	}}
+	s := NewService(nil, kpisDAO, nil, nil, nil)
+
+	t.Run("findValidKpi fills in the defaults of stored kpis", func(t *testing.T) {
+		kpi, err := s.findValidKpi(1, "owner")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kpi.ModelID != DefaultModelIDValue || kpi.Dimension != DefaultDimension {
+			t.Errorf("findValidKpi returned a kpi without defaults: got %+v", kpi)
+		}
+	})
+
+	t.Run("findValidKpi rejects stored kpis that are no longer valid", func(t *testing.T) {
+		if _, err := s.findValidKpi(2, "owner"); err != ErrUnknownColumn {
+			t.Errorf("findValidKpi returned wrong error: got %v want %v",
+				err, ErrUnknownColumn)
+		}
+	})
+}
+
+func TestKpiJSON(t *testing.T) {
+
+	t.Run("kpis listed without aggregates leave them out", func(t *testing.T) {
+		body, err := json.Marshal(Kpi{})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(body), "campaignNameJourneyAggregate") || strings.Contains(string(body), `"attribution"`) {
+			t.Errorf("Kpi marshalled with aggregates: got %s", body)
+		}
+	})
+
+	t.Run("kpis without conversions have empty aggregates", func(t *testing.T) {
+		aggregate, attribution := []PosAggregate{}, []CreditAggregate{}
+
+		body, err := json.Marshal(Kpi{JourneyAggregate: &aggregate, Attribution: &attribution})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), `"campaignNameJourneyAggregate":[]`) || !strings.Contains(string(body), `"attribution":[]`) {
+			t.Errorf("Kpi marshalled without empty aggregates: got %s", body)
+		}
+	})
+}
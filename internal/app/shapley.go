@@ -0,0 +1,217 @@
+package app
+
+import (
+	"math/rand"
+	"sort"
+)
+
+const (
+	// Owners with more channels than this get approximate shapley values from
+	// sampled permutations, since the exact values take 2^channels steps
+	shapleyMaxExactChannels = 12
+	shapleySamples          = 2000
+	// Sampling is seeded so the same journeys always give the same values
+	shapleySeed = 1
+)
+
+// ShapleyValues is what the shapley model learnt from every visitor's journey
+type ShapleyValues struct {
+	// Values is each channel's average marginal contribution to the
+	// conversion rate over every order channels could be added in
+	Values       map[string]float64 `json:"values"`
+	Approximated bool               `json:"approximated"`
+}
+
+// coalition is a set of channels that visitors were touched by, along with how
+// often those visitors converted
+type coalition struct {
+	channels       []int
+	conversionRate float64
+}
+
+// shapleyModel computes each channel's shapley value from the visitors'
+// journeys and credits each conversion's touches in proportion to them
+func shapleyModel(visitors []journey) (attributionModel, interface{}) {
+	channels, coalitions := findCoalitions(visitors)
+
+	var values []float64
+	result := ShapleyValues{Values: map[string]float64{}}
+	if len(channels) <= shapleyMaxExactChannels {
+		values = exactShapley(len(channels), coalitions)
+	} else {
+		values = sampledShapley(len(channels), coalitions, shapleySamples)
+		result.Approximated = true
+	}
+	for i, channel := range channels {
+		result.Values[channel] = values[i]
+	}
+
+	model := func(kpi Kpi, j journey) []float64 {
+		credit := make([]float64, len(j))
+		total := 0.0
+		for i, t := range j {
+			credit[i] = result.Values[t.Value]
+			total += credit[i]
+		}
+		if total <= 0 {
+			return linear(kpi, j)
+		}
+		for i := range credit {
+			credit[i] /= total
+		}
+		return credit
+	}
+
+	return model, result
+}
+
+// findCoalitions returns every channel along with the conversion rate of each
+// distinct set of channels visitors were touched by
+func findCoalitions(visitors []journey) ([]string, []coalition) {
+	channelSet := map[string]bool{}
+	for _, j := range visitors {
+		for _, t := range j {
+			channelSet[t.Value] = true
+		}
+	}
+	channels := make([]string, 0, len(channelSet))
+	for channel := range channelSet {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	index := map[string]int{}
+	for i, channel := range channels {
+		index[channel] = i
+	}
+
+	type tally struct {
+		channels              []int
+		visitors, conversions float64
+	}
+	tallies := map[string]*tally{}
+	keys := []string{}
+	for _, j := range visitors {
+		members := map[int]bool{}
+		for _, t := range j {
+			members[index[t.Value]] = true
+		}
+		sorted := make([]int, 0, len(members))
+		for i := range members {
+			sorted = append(sorted, i)
+		}
+		sort.Ints(sorted)
+
+		key := ""
+		for _, i := range sorted {
+			key += channels[i] + "\x00"
+		}
+		if tallies[key] == nil {
+			tallies[key] = &tally{channels: sorted}
+			keys = append(keys, key)
+		}
+		tallies[key].visitors++
+		if j[0].Converted {
+			tallies[key].conversions++
+		}
+	}
+
+	coalitions := make([]coalition, 0, len(keys))
+	for _, key := range keys {
+		t := tallies[key]
+		coalitions = append(coalitions, coalition{
+			channels:       t.channels,
+			conversionRate: t.conversions / t.visitors,
+		})
+	}
+
+	return channels, coalitions
+}
+
+// exactShapley computes shapley values where the worth of a set of channels is
+// the summed conversion rate of every coalition inside it
+func exactShapley(n int, coalitions []coalition) []float64 {
+	// worth[mask] starts as the rate of the coalition that is exactly mask
+	// and is then summed over every subset of mask
+	worth := make([]float64, 1<<uint(n))
+	for _, c := range coalitions {
+		mask := 0
+		for _, i := range c.channels {
+			mask |= 1 << uint(i)
+		}
+		worth[mask] += c.conversionRate
+	}
+	for i := 0; i < n; i++ {
+		for mask := range worth {
+			if mask&(1<<uint(i)) != 0 {
+				worth[mask] += worth[mask^(1<<uint(i))]
+			}
+		}
+	}
+
+	// weights[k] is k!(n-k-1)!/n!, the chance that channel i joins right after
+	// a particular set of k other channels
+	weights := make([]float64, n)
+	for k := range weights {
+		w := 1.0 / float64(n)
+		for j := 1; j <= k; j++ {
+			w *= float64(j) / float64(n-j)
+		}
+		weights[k] = w
+	}
+
+	values := make([]float64, n)
+	for mask := range worth {
+		size := bitCount(mask)
+		for i := 0; i < n; i++ {
+			bit := 1 << uint(i)
+			if mask&bit != 0 {
+				continue
+			}
+			values[i] += weights[size] * (worth[mask|bit] - worth[mask])
+		}
+	}
+
+	return values
+}
+
+// sampledShapley approximates shapley values by averaging each channel's
+// marginal contribution over randomly ordered permutations of channels
+func sampledShapley(n int, coalitions []coalition, samples int) []float64 {
+	containing := make([][]int, n)
+	for c, co := range coalitions {
+		for _, i := range co.channels {
+			containing[i] = append(containing[i], c)
+		}
+	}
+
+	random := rand.New(rand.NewSource(shapleySeed))
+	values := make([]float64, n)
+	missing := make([]int, len(coalitions))
+	for sample := 0; sample < samples; sample++ {
+		for c, co := range coalitions {
+			missing[c] = len(co.channels)
+		}
+		// A coalition adds its rate once its last channel has joined
+		for _, i := range random.Perm(n) {
+			for _, c := range containing[i] {
+				missing[c]--
+				if missing[c] == 0 {
+					values[i] += coalitions[c].conversionRate
+				}
+			}
+		}
+	}
+
+	for i := range values {
+		values[i] /= float64(samples)
+	}
+	return values
+}
+
+func bitCount(mask int) int {
+	count := 0
+	for ; mask != 0; mask &= mask - 1 {
+		count++
+	}
+	return count
+}
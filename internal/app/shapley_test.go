@@ -0,0 +1,40 @@
+package app
+
+import (
+	"math"
+	"testing"
+)
+
+func TestShapley(t *testing.T) {
+	visitors := []journey{
+		{{AnonymousID: "a", Value: "Paid Search", Converted: true}},
+		{{AnonymousID: "b", Value: "Social"}},
+		{{AnonymousID: "c", Value: "Paid Search", Converted: true}, {AnonymousID: "c", Value: "Social", Converted: true}},
+	}
+	channels, coalitions := findCoalitions(visitors)
+	expected := []float64{1.5, 0.5}
+
+	t.Run("exactShapley averages marginal contributions over every ordering", func(t *testing.T) {
+		values := exactShapley(len(channels), coalitions)
+
+		for i := range expected {
+			if math.Abs(values[i]-expected[i]) > 1e-9 {
+				t.Errorf("exactShapley returned unexpected values: got %v want %v",
+					values, expected)
+				break
+			}
+		}
+	})
+
+	t.Run("sampledShapley approximates the exact values", func(t *testing.T) {
+		values := sampledShapley(len(channels), coalitions, shapleySamples)
+
+		for i := range expected {
+			if math.Abs(values[i]-expected[i]) > 0.05 {
+				t.Errorf("sampledShapley returned unexpected values: got %v want %v",
+					values, expected)
+				break
+			}
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package app
+
+import "time"
+
+// Periods a kpi's target can be set for
+const (
+	WeeklyTargetPeriod    = "weekly"
+	MonthlyTargetPeriod   = "monthly"
+	QuarterlyTargetPeriod = "quarterly"
+	DefaultTargetPeriod   = MonthlyTargetPeriod
+)
+
+var (
+	ErrUnknownTargetPeriod = ValidationError("The targetPeriod must be weekly, monthly or quarterly.")
+	ErrNegativeTarget      = ValidationError("The target can't be negative.")
+)
+
+var targetPeriods = map[string]bool{
+	WeeklyTargetPeriod:    true,
+	MonthlyTargetPeriod:   true,
+	QuarterlyTargetPeriod: true,
+}
+
+// TargetProgress is how a kpi's conversions in the current target period
+// compare to its target
+type TargetProgress struct {
+	PeriodStart     time.Time `json:"periodStart"`
+	PeriodEnd       time.Time `json:"periodEnd"`
+	Conversions     int64     `json:"conversions"`
+	PercentOfTarget float64   `json:"percentOfTarget"`
+	// Projected is how many conversions there will be by the end of the period
+	// if they keep coming in at the same rate
+	Projected float64 `json:"projected"`
+	OnTrack   bool    `json:"onTrack"`
+}
+
+// currentTargetPeriod returns when the target period that now is in starts and
+// ends, in now's location. Weeks start on Monday.
+func currentTargetPeriod(period string, now time.Time) (time.Time, time.Time) {
+	switch period {
+	case WeeklyTargetPeriod:
+		start := truncatePeriod(now, WeekGranularity)
+		return start, start.AddDate(0, 0, 7)
+	case QuarterlyTargetPeriod:
+		month := truncatePeriod(now, MonthGranularity)
+		start := month.AddDate(0, -(int(month.Month()-1) % 3), 0)
+		return start, start.AddDate(0, 3, 0)
+	}
+	start := truncatePeriod(now, MonthGranularity)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// targetProgress compares the conversions since start with the kpi's target,
+// projecting the run rate so far to the end of the period
+func targetProgress(kpi Kpi, conversions int64, start, end, now time.Time) TargetProgress {
+	progress := TargetProgress{
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Conversions: conversions,
+	}
+
+	progress.PercentOfTarget = float64(conversions) / float64(kpi.Target) * 100
+	elapsed := now.Sub(start)
+	if elapsed > 0 {
+		progress.Projected = float64(conversions) * float64(end.Sub(start)) / float64(elapsed)
+	}
+	progress.OnTrack = progress.Projected >= float64(kpi.Target)
+
+	return progress
+}
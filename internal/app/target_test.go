@@ -0,0 +1,51 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCurrentTargetPeriod(t *testing.T) {
+	// A Thursday in the second quarter
+	now := time.Date(2020, 5, 14, 15, 0, 0, 0, time.UTC)
+	tests := map[string][2]time.Time{
+		WeeklyTargetPeriod:    {time.Date(2020, 5, 11, 0, 0, 0, 0, time.UTC), time.Date(2020, 5, 18, 0, 0, 0, 0, time.UTC)},
+		MonthlyTargetPeriod:   {time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)},
+		QuarterlyTargetPeriod: {time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for period, expected := range tests {
+		t.Run("currentTargetPeriod finds the "+period+" period", func(t *testing.T) {
+			start, end := currentTargetPeriod(period, now)
+
+			if !start.Equal(expected[0]) || !end.Equal(expected[1]) {
+				t.Errorf("currentTargetPeriod returned wrong period: got %v to %v want %v to %v",
+					start, end, expected[0], expected[1])
+			}
+		})
+	}
+}
+
+func TestTargetProgress(t *testing.T) {
+	start := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 30)
+	now := start.AddDate(0, 0, 10)
+
+	t.Run("targetProgress projects the run rate to the end of the period", func(t *testing.T) {
+		progress := targetProgress(Kpi{Target: 100}, 40, start, end, now)
+
+		if progress.PercentOfTarget != 40 || progress.Projected != 120 || !progress.OnTrack {
+			t.Errorf("targetProgress returned wrong progress: got %+v want 40%% projecting 120 on track",
+				progress)
+		}
+	})
+
+	t.Run("targetProgress is off track when the projection falls short", func(t *testing.T) {
+		progress := targetProgress(Kpi{Target: 100}, 20, start, end, now)
+
+		if progress.Projected != 60 || progress.OnTrack {
+			t.Errorf("targetProgress returned wrong progress: got %+v want projecting 60 off track",
+				progress)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package app
+
+import "time"
+
+// Granularities a kpi timeseries can be bucketed by
+const (
+	DayGranularity   = "day"
+	WeekGranularity  = "week"
+	MonthGranularity = "month"
+)
+
+var granularities = map[string]bool{
+	DayGranularity:   true,
+	WeekGranularity:  true,
+	MonthGranularity: true,
+}
+
+// truncatePeriod returns the start of the period t is in. Weeks start on
+// Monday, like postgres' date_trunc.
+func truncatePeriod(t time.Time, granularity string) time.Time {
+	day := truncateDay(t)
+	switch granularity {
+	case WeekGranularity:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case MonthGranularity:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+// nextPeriod returns the start of the period after the one starting at t
+func nextPeriod(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case WeekGranularity:
+		return t.AddDate(0, 0, 7)
+	case MonthGranularity:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// timeseriesBounds returns the first and last period of a timeseries, which are
+// those of the date range, or first and last where the range is open
+func timeseriesBounds(first, last time.Time, granularity string, dateRange DateRange) (time.Time, time.Time) {
+	location := dateRange.Location
+	if location == nil {
+		location = time.UTC
+	}
+	if !dateRange.From.IsZero() {
+		first = truncatePeriod(dateRange.From.In(location), granularity)
+	}
+	if !dateRange.To.IsZero() {
+		last = truncatePeriod(dateRange.To.Add(-time.Nanosecond).In(location), granularity)
+	}
+	return first, last
+}
+
+// tooManyPeriods reports whether there are more than MaxTimeseriesPoints periods
+// from first to last
+func tooManyPeriods(first, last time.Time, granularity string) bool {
+	period := first
+	for i := 0; i < MaxTimeseriesPoints; i++ {
+		if period.After(last) {
+			return false
+		}
+		period = nextPeriod(period, granularity)
+	}
+	return !period.After(last)
+}
+
+// fillTimeseries puts the conversion counts and credit into one point per
+// period, including periods without conversions. Points cover the date range,
+// or from the first to the last period with data if it is open.
+func fillTimeseries(counts []TimeseriesPoint, credits []CreditAggregate, granularity string, dateRange DateRange) ([]TimeseriesPoint, error) {
+	location := dateRange.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	var first, last time.Time
+	extend := func(period time.Time) {
+		if first.IsZero() || period.Before(first) {
+			first = period
+		}
+		if last.IsZero() || period.After(last) {
+			last = period
+		}
+	}
+
+	conversions := map[time.Time]int64{}
+	for _, c := range counts {
+		period := truncatePeriod(c.Period.In(location), granularity)
+		conversions[period] += c.Conversions
+		extend(period)
+	}
+
+	credit := map[time.Time]map[string]float64{}
+	values := []string{}
+	seen := map[string]bool{}
+	for _, c := range credits {
+		period := truncatePeriod(c.Day.In(location), granularity)
+		if credit[period] == nil {
+			credit[period] = map[string]float64{}
+		}
+		credit[period][c.Value] += c.Conversions
+		if !seen[c.Value] {
+			seen[c.Value] = true
+			values = append(values, c.Value)
+		}
+		extend(period)
+	}
+
+	first, last = timeseriesBounds(first, last, granularity, dateRange)
+
+	points := []TimeseriesPoint{}
+	if first.IsZero() {
+		return points, nil
+	}
+	if tooManyPeriods(first, last, granularity) {
+		return nil, ErrTooManyPoints
+	}
+	for period := first; !period.After(last); period = nextPeriod(period, granularity) {
+		point := TimeseriesPoint{
+			Period:      period,
+			Conversions: conversions[period],
+			Credit:      map[string]float64{},
+		}
+		for _, value := range values {
+			point.Credit[value] = credit[period][value]
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTruncatePeriod(t *testing.T) {
+	// A Thursday
+	sentAt := time.Date(2020, 1, 16, 15, 4, 5, 0, time.UTC)
+	tests := map[string]time.Time{
+		DayGranularity:   time.Date(2020, 1, 16, 0, 0, 0, 0, time.UTC),
+		WeekGranularity:  time.Date(2020, 1, 13, 0, 0, 0, 0, time.UTC),
+		MonthGranularity: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for granularity, expected := range tests {
+		t.Run("truncatePeriod finds the start of the "+granularity, func(t *testing.T) {
+			if period := truncatePeriod(sentAt, granularity); !period.Equal(expected) {
+				t.Errorf("truncatePeriod returned wrong period: got %v want %v",
+					period, expected)
+			}
+		})
+	}
+}
+
+func TestFillTimeseries(t *testing.T) {
+	day1 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	day3 := time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)
+	counts := []TimeseriesPoint{{Period: day1, Conversions: 2}}
+	credits := []CreditAggregate{
+		{Value: "Paid Search", Day: day1, Conversions: 1.5},
+		{Value: "Blog", Day: day1, Conversions: 0.5},
+		{Value: "Blog", Day: day3, Conversions: 1},
+	}
+
+	t.Run("fillTimeseries adds empty periods between the first and last", func(t *testing.T) {
+		points, err := fillTimeseries(counts, credits, DayGranularity, DateRange{Location: time.UTC})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(points) != 3 {
+			t.Fatalf("fillTimeseries returned wrong number of points: got %v want %v",
+				len(points), 3)
+		}
+		expected := map[string]float64{"Paid Search": 0, "Blog": 0}
+		if points[1].Conversions != 0 || !reflect.DeepEqual(points[1].Credit, expected) {
+			t.Errorf("fillTimeseries returned a non-empty gap: got %v want %v",
+				points[1].Credit, expected)
+		}
+		if points[2].Credit["Blog"] != 1 {
+			t.Errorf("fillTimeseries returned wrong credit: got %v want %v",
+				points[2].Credit["Blog"], 1)
+		}
+	})
+
+	t.Run("fillTimeseries covers the whole date range", func(t *testing.T) {
+		dateRange := DateRange{
+			From:     day1,
+			To:       time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC),
+			Location: time.UTC,
+		}
+
+		points, err := fillTimeseries(counts, credits, WeekGranularity, dateRange)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Weeks starting Dec 30, Jan 6, 13, 20 and 27
+		if len(points) != 5 {
+			t.Errorf("fillTimeseries returned wrong number of points: got %v want %v",
+				len(points), 5)
+		}
+		if points[0].Conversions != 2 || points[0].Credit["Blog"] != 1.5 {
+			t.Errorf("fillTimeseries returned wrong first week: got %v", points[0])
+		}
+	})
+
+	t.Run("fillTimeseries rejects too many periods", func(t *testing.T) {
+		dateRange := DateRange{
+			From:     day1,
+			To:       time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+			Location: time.UTC,
+		}
+
+		_, err := fillTimeseries(counts, credits, DayGranularity, dateRange)
+
+		if err != ErrTooManyPoints {
+			t.Errorf("fillTimeseries returned wrong error: got %v want %v",
+				err, ErrTooManyPoints)
+		}
+	})
+}
+
+func TestTooManyPeriods(t *testing.T) {
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		last     time.Time
+		expected bool
+	}{
+		{"one period is fine", first, false},
+		{"the max is fine", first.AddDate(0, 0, MaxTimeseriesPoints-1), false},
+		{"one more than the max is too many", first.AddDate(0, 0, MaxTimeseriesPoints), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := tooManyPeriods(first, test.last, DayGranularity); got != test.expected {
+				t.Errorf("tooManyPeriods returned wrong result: got %v want %v",
+					got, test.expected)
+			}
+		})
+	}
+}
@@ -1,13 +1,20 @@
 package http
 
 import (
+	"bufio"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/gorilla/mux"
 
@@ -18,9 +25,16 @@ const (
 	invalidRequestError              = "The request you sent is invalid. Please reformat the request and try again."
 	invalidBase64EncodingError       = "The data sent was not Base64 encoded. Please encode the data and try again."
 	invalidJwtError                  = `{"error": "Invalid JWT"}`
+	kpiNotFoundError                 = `{"error": "No kpi was found with that id."}`
+	unknownSecretError               = `{"error": "No user was found for that secret."}`
+	invalidDateRangeError            = "The date range you sent is invalid. Send from and to as YYYY-MM-DD or RFC 3339 times and timezone as an IANA time zone name."
+	dateLayout                       = "2006-01-02"
 	internalError                    = `{"error": "We experienced an internal error. Please try again later."}`
 	authClaimsDecodingError          = "Couldn't decode auth claims."
 	mockOwnerID                int64 = 0
+	// maxTrackBytes and maxTrackBatchBytes are the largest track and batch bodies that will be read
+	maxTrackBytes      = 64 << 10
+	maxTrackBatchBytes = 8 << 20
 )
 
 var (
@@ -30,6 +44,8 @@ var (
 		1, 0, 1, 0, 0, 2, 1, 68, 0, 59,
 	}
 	contextKeyClaims ContextKey = "claims"
+
+	errUnexpectedTrackData = errors.New("unexpected data after the tracks")
 )
 
 type ContextKey string
@@ -52,12 +68,27 @@ func NewHandler(service app.Service, auth0Domain, auth0ApiID string) *Handler {
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	router := mux.NewRouter()
 	router.HandleFunc("/tracks/new", h.newTrack).Methods("GET")
+	router.HandleFunc("/tracks", h.postTrack).Methods("POST")
+	router.HandleFunc("/tracks/batch", h.postTrackBatch).Methods("POST")
+	router.HandleFunc("/v1/{type:track|page|identify}", h.segmentMessage).Methods("POST")
+	router.HandleFunc("/v1/batch", h.segmentBatch).Methods("POST")
+	router.HandleFunc("/mp/collect", h.measurementProtocol).Methods("POST")
+	router.HandleFunc("/billing_events", h.newBillingEvent).Methods("POST")
 
 	s := router.PathPrefix("/").Subrouter()
 	s.HandleFunc("/kpis", h.newKpi).Methods("POST")
 	s.HandleFunc("/kpis/{id:[0-9]+}", h.deleteKpi).Methods("DELETE")
 	s.HandleFunc("/kpis/{id:[0-9]+}", h.updateKpi).Methods("PUT")
 	s.HandleFunc("/kpis", h.listKpis).Methods("GET")
+	s.HandleFunc("/kpis/{id:[0-9]+}", h.getKpi).Methods("GET")
+	s.HandleFunc("/kpis/{id:[0-9]+}/compare", h.compareKpiModels).Methods("GET")
+	s.HandleFunc("/kpis/{id:[0-9]+}/timeseries", h.kpiTimeseries).Methods("GET")
+	s.HandleFunc("/kpis/{id:[0-9]+}/funnel", h.kpiFunnel).Methods("GET")
+	s.HandleFunc("/weights", h.newWeight).Methods("POST")
+	s.HandleFunc("/weights/{id:[0-9]+}", h.deleteWeight).Methods("DELETE")
+	s.HandleFunc("/weights/{id:[0-9]+}", h.updateWeight).Methods("PUT")
+	s.HandleFunc("/weights", h.listWeights).Methods("GET")
+	s.HandleFunc("/weights/unweighted", h.listUnweightedValues).Methods("GET")
 	s.Use(h.newJwtMiddleware())
 	s.Use(h.addJwtTokenClaimsInContextMiddleware)
 
@@ -108,6 +139,189 @@ func (h *Handler) newTrack(w http.ResponseWriter, r *http.Request) {
 	w.Write(gif)
 }
 
+// postTrack stores a track sent as a JSON body. Bodies sent with navigator.sendBeacon
+// are text/plain, so the body is read as JSON whatever its content type.
+func (h *Handler) postTrack(w http.ResponseWriter, r *http.Request) {
+	secret := r.URL.Query().Get("secret")
+
+	// Parse body
+	track := app.Track{}
+	r.Body = http.MaxBytesReader(w, r.Body, maxTrackBytes)
+	if err := json.NewDecoder(r.Body).Decode(&track); err != nil {
+		http.Error(w, invalidRequestError, http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+
+	// Grab IP
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	track.IP = ip
+
+	// Store raw track
+	newTrackID, err := h.service.NewTrack(track, secret)
+	if err == app.ErrUnknownSecret {
+		http.Error(w, unknownSecretError, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println("Error storing track: ", err)
+		return
+	}
+
+	// Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int64{"id": newTrackID})
+}
+
+// postTrackBatch stores every track in a JSON array or newline delimited JSON body
+func (h *Handler) postTrackBatch(w http.ResponseWriter, r *http.Request) {
+	secret := r.URL.Query().Get("secret")
+
+	// Parse body
+	r.Body = http.MaxBytesReader(w, r.Body, maxTrackBatchBytes)
+	tracks, err := parseTracks(r.Body)
+	if err == app.ErrTooManyTracks {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, invalidRequestError, http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+
+	// Grab IP
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	for i := range tracks {
+		tracks[i].IP = ip
+	}
+
+	// Store raw tracks
+	ids, err := h.service.NewTracks(tracks, secret)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == app.ErrUnknownSecret {
+		http.Error(w, unknownSecretError, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println("Error storing tracks: ", err)
+		return
+	}
+
+	// Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string][]int64{"ids": ids})
+}
+
+// parseTracks reads tracks from a JSON array or from newline delimited JSON, stopping
+// once there are more than app.MaxTrackBatch
+func parseTracks(body io.Reader) ([]app.Track, error) {
+	reader := bufio.NewReader(body)
+	decoder := json.NewDecoder(reader)
+	tracks := []app.Track{}
+	isArray := false
+
+	// Peek past any whitespace to tell arrays from newline delimited JSON
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return tracks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == '[' {
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			isArray = true
+			break
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			break
+		}
+		reader.ReadByte()
+	}
+
+	for decoder.More() {
+		if len(tracks) == app.MaxTrackBatch {
+			return nil, app.ErrTooManyTracks
+		}
+		var track app.Track
+		if err := decoder.Decode(&track); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+
+	if isArray {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if token != json.Delim(']') {
+			return nil, errUnexpectedTrackData
+		}
+	}
+	// Only whitespace can follow the tracks
+	rest, err := ioutil.ReadAll(io.MultiReader(decoder.Buffered(), reader))
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(rest))) > 0 {
+		return nil, errUnexpectedTrackData
+	}
+
+	return tracks, nil
+}
+
+// ~=~=~=~=~=~=~=~=
+// Billing events
+// ~=~=~=~=~=~=~=~=
+
+// newBillingEvent stores a payment made by one of the owner's users, sent from
+// the owner's backend with their secret
+func (h *Handler) newBillingEvent(w http.ResponseWriter, r *http.Request) {
+	var billingEvent app.BillingEvent
+	secret := r.URL.Query().Get("secret")
+
+	// Parse body
+	err := json.NewDecoder(r.Body).Decode(&billingEvent)
+	if err != nil {
+		http.Error(w, invalidRequestError, http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+
+	// Store billing event
+	newBillingEventID, err := h.service.NewBillingEvent(billingEvent, secret)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == app.ErrUnknownSecret {
+		http.Error(w, unknownSecretError, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	s := strconv.FormatInt(newBillingEventID, 10)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, s)
+}
+
 // ~=~=~=~=~=~=~=~=
 // Kpis
 // ~=~=~=~=~=~=~=~=
@@ -127,6 +341,10 @@ func (h *Handler) newKpi(w http.ResponseWriter, r *http.Request) {
 
 	// Store KPI
 	newKpiID, err := h.service.NewKpi(kpi)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, internalError, http.StatusInternalServerError)
 		log.Println(err)
@@ -141,19 +359,32 @@ func (h *Handler) newKpi(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) updateKpi(w http.ResponseWriter, r *http.Request) {
 	var kpi app.Kpi
+	vars := mux.Vars(r)
+	idString := vars["id"]
 	claims := r.Context().Value(contextKeyClaims).(customClaims)
 
+	id, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil {
+		http.Error(w, "id error", http.StatusBadRequest)
+		return
+	}
+
 	// Parse body
-	err := json.NewDecoder(r.Body).Decode(&kpi)
+	err = json.NewDecoder(r.Body).Decode(&kpi)
 	if err != nil {
 		http.Error(w, invalidRequestError, http.StatusBadRequest)
 		log.Println(err)
 		return
 	}
+	kpi.ID = id
 	kpi.OwnerID = claims.UserID
 
 	// Store KPI
 	err = h.service.UpdateKpi(kpi)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, internalError, http.StatusInternalServerError)
 		log.Println(err)
@@ -197,8 +428,26 @@ func (h *Handler) deleteKpi(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) listKpis(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value(contextKeyClaims).(customClaims)
 
+	dateRange, err := parseDateRange(r)
+	if err != nil {
+		http.Error(w, invalidDateRangeError, http.StatusBadRequest)
+		return
+	}
+
+	// Aggregates are only computed when asked for, since they need queries per kpi
+	includeAggregates := false
+	for _, include := range strings.Split(r.URL.Query().Get("include"), ",") {
+		if strings.TrimSpace(include) == "aggregates" {
+			includeAggregates = true
+		}
+	}
+
 	// Get Kpis
-	kpis, err := h.service.GetKpisForUser(claims.UserID)
+	kpis, err := h.service.GetKpisForUser(claims.UserID, r.URL.Query().Get("dimension"), dateRange, includeAggregates)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, internalError, http.StatusInternalServerError)
 		log.Println(err)
@@ -210,3 +459,374 @@ func (h *Handler) listKpis(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(kpis)
 }
+
+// getKpi returns one kpi with its aggregates
+func (h *Handler) getKpi(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idString := vars["id"]
+	claims := r.Context().Value(contextKeyClaims).(customClaims)
+
+	id, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil {
+		http.Error(w, "id error", http.StatusBadRequest)
+		return
+	}
+
+	dateRange, err := parseDateRange(r)
+	if err != nil {
+		http.Error(w, invalidDateRangeError, http.StatusBadRequest)
+		return
+	}
+
+	// Get Kpi
+	kpi, err := h.service.GetKpi(id, claims.UserID, r.URL.Query().Get("dimension"), dateRange)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == app.ErrKpiNotFound {
+		http.Error(w, kpiNotFoundError, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(kpi)
+}
+
+// compareKpiModels attributes a kpi's conversions with every model in the
+// comma separated models query param
+func (h *Handler) compareKpiModels(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idString := vars["id"]
+	claims := r.Context().Value(contextKeyClaims).(customClaims)
+
+	id, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil {
+		http.Error(w, "id error", http.StatusBadRequest)
+		return
+	}
+
+	var modelIDs []string
+	if models := r.URL.Query().Get("models"); models != "" {
+		modelIDs = strings.Split(models, ",")
+	}
+
+	dateRange, err := parseDateRange(r)
+	if err != nil {
+		http.Error(w, invalidDateRangeError, http.StatusBadRequest)
+		return
+	}
+
+	// Compare models
+	comparison, err := h.service.CompareModels(id, claims.UserID, r.URL.Query().Get("dimension"), modelIDs, dateRange)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == app.ErrKpiNotFound {
+		http.Error(w, kpiNotFoundError, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// kpiTimeseries returns a kpi's conversions and attributed credit per day, week
+// or month
+func (h *Handler) kpiTimeseries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idString := vars["id"]
+	claims := r.Context().Value(contextKeyClaims).(customClaims)
+
+	id, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil {
+		http.Error(w, "id error", http.StatusBadRequest)
+		return
+	}
+
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = app.DayGranularity
+	}
+
+	dateRange, err := parseDateRange(r)
+	if err != nil {
+		http.Error(w, invalidDateRangeError, http.StatusBadRequest)
+		return
+	}
+
+	// Get timeseries
+	timeseries, err := h.service.GetKpiTimeseries(id, claims.UserID, r.URL.Query().Get("dimension"), granularity, dateRange)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == app.ErrKpiNotFound {
+		http.Error(w, kpiNotFoundError, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(timeseries)
+}
+
+// kpiFunnel returns how many visitors reached each step of a funnel kpi and the
+// attribution of the visitors that completed it
+func (h *Handler) kpiFunnel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idString := vars["id"]
+	claims := r.Context().Value(contextKeyClaims).(customClaims)
+
+	id, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil {
+		http.Error(w, "id error", http.StatusBadRequest)
+		return
+	}
+
+	dateRange, err := parseDateRange(r)
+	if err != nil {
+		http.Error(w, invalidDateRangeError, http.StatusBadRequest)
+		return
+	}
+
+	// Get funnel
+	funnel, err := h.service.GetFunnel(id, claims.UserID, r.URL.Query().Get("dimension"), dateRange)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == app.ErrKpiNotFound {
+		http.Error(w, kpiNotFoundError, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(funnel)
+}
+
+// parseDateRange reads the from, to and timezone query params. Dates without a
+// time are days in the timezone, and to includes the whole day.
+func parseDateRange(r *http.Request) (app.DateRange, error) {
+	v := r.URL.Query()
+	dateRange := app.DateRange{Location: time.UTC}
+
+	if timezone := v.Get("timezone"); timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return dateRange, err
+		}
+		dateRange.Location = location
+	}
+
+	if from := v.Get("from"); from != "" {
+		t, _, err := parseDateParam(from, dateRange.Location)
+		if err != nil {
+			return dateRange, err
+		}
+		dateRange.From = t
+	}
+
+	if to := v.Get("to"); to != "" {
+		t, isDate, err := parseDateParam(to, dateRange.Location)
+		if err != nil {
+			return dateRange, err
+		}
+		if isDate {
+			t = t.AddDate(0, 0, 1)
+		}
+		dateRange.To = t
+	}
+
+	if !dateRange.From.IsZero() && !dateRange.To.IsZero() && !dateRange.From.Before(dateRange.To) {
+		return dateRange, errors.New("from must be before to")
+	}
+
+	return dateRange, nil
+}
+
+// parseDateParam parses a YYYY-MM-DD date in location or an RFC 3339 time, and
+// reports whether it was a date
+func parseDateParam(value string, location *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(dateLayout, value, location); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// ~=~=~=~=~=~=~=~=
+// Weights
+// ~=~=~=~=~=~=~=~=
+
+func (h *Handler) newWeight(w http.ResponseWriter, r *http.Request) {
+	var weight app.Weight
+	claims := r.Context().Value(contextKeyClaims).(customClaims)
+
+	// Parse body
+	err := json.NewDecoder(r.Body).Decode(&weight)
+	if err != nil {
+		http.Error(w, invalidRequestError, http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	weight.OwnerID = claims.UserID
+
+	// Store weight
+	newWeightID, err := h.service.NewWeight(weight)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	s := strconv.FormatInt(newWeightID, 10)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, s)
+}
+
+func (h *Handler) updateWeight(w http.ResponseWriter, r *http.Request) {
+	var weight app.Weight
+	vars := mux.Vars(r)
+	idString := vars["id"]
+	claims := r.Context().Value(contextKeyClaims).(customClaims)
+
+	id, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil {
+		http.Error(w, "id error", http.StatusBadRequest)
+		return
+	}
+
+	// Parse body
+	err = json.NewDecoder(r.Body).Decode(&weight)
+	if err != nil {
+		http.Error(w, invalidRequestError, http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	weight.ID = id
+	weight.OwnerID = claims.UserID
+
+	// Store weight
+	err = h.service.UpdateWeight(weight)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) deleteWeight(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idString := vars["id"]
+	claims := r.Context().Value(contextKeyClaims).(customClaims)
+
+	id, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil {
+		http.Error(w, "id error", http.StatusBadRequest)
+		return
+	}
+
+	weight := app.Weight{
+		ID:      id,
+		OwnerID: claims.UserID,
+	}
+
+	// Delete weight
+	deleted, err := h.service.DeleteWeight(weight)
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	s := strconv.FormatInt(deleted, 10)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, s)
+}
+
+func (h *Handler) listWeights(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(contextKeyClaims).(customClaims)
+	columnName := r.URL.Query().Get("column")
+
+	// Get weights
+	weights, err := h.service.GetWeightsForUser(claims.UserID, columnName)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(weights)
+}
+
+// listUnweightedValues lists the values of a column that have been tracked but don't have a weight yet
+func (h *Handler) listUnweightedValues(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(contextKeyClaims).(customClaims)
+	columnName := r.URL.Query().Get("column")
+
+	// Get values
+	values, err := h.service.GetUnweightedValues(claims.UserID, columnName)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	// Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(values)
+}
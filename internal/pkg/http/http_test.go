@@ -0,0 +1,92 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattribution/api/internal/app"
+)
+
+func TestParseDateRange(t *testing.T) {
+
+	t.Run("parseDateRange includes the whole of the to day in the timezone", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/kpis?from=2020-01-01&to=2020-01-31&timezone=America/New_York", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		location, err := time.LoadLocation("America/New_York")
+		if err != nil {
+			t.Skip("time zone database isn't available")
+		}
+		expectedFrom := time.Date(2020, 1, 1, 0, 0, 0, 0, location)
+		expectedTo := time.Date(2020, 2, 1, 0, 0, 0, 0, location)
+
+		dateRange, err := parseDateRange(req)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !dateRange.From.Equal(expectedFrom) || !dateRange.To.Equal(expectedTo) {
+			t.Errorf("parseDateRange returned unexpected range: got %v - %v want %v - %v",
+				dateRange.From, dateRange.To, expectedFrom, expectedTo)
+		}
+	})
+
+	t.Run("parseDateRange rejects a from that isn't before to", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/kpis?from=2020-02-01T00:00:00Z&to=2020-01-01T00:00:00Z", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := parseDateRange(req); err == nil {
+			t.Error("parseDateRange accepted an empty range")
+		}
+	})
+}
+
+func TestParseTracks(t *testing.T) {
+	bodies := map[string]string{
+		"a JSON array":           ` [{"event": "signup"}, {"event": "key_page_view"}]`,
+		"newline delimited JSON": "{\"event\": \"signup\"}\n{\"event\": \"key_page_view\"}\n",
+	}
+
+	for name, body := range bodies {
+		t.Run("parseTracks reads "+name, func(t *testing.T) {
+			tracks, err := parseTracks(strings.NewReader(body))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tracks) != 2 || tracks[0].Event != "signup" || tracks[1].Event != "key_page_view" {
+				t.Errorf("parseTracks returned unexpected tracks: got %+v", tracks)
+			}
+		})
+	}
+
+	invalid := map[string]string{
+		"an unterminated array":        `[{"event": "signup"}`,
+		"an array followed by junk":    `[{"event": "signup"}] {"event": "key_page_view"}`,
+		"newline delimited JSON and ]": "{\"event\": \"signup\"}\n]",
+	}
+
+	for name, body := range invalid {
+		t.Run("parseTracks rejects "+name, func(t *testing.T) {
+			if _, err := parseTracks(strings.NewReader(body)); err == nil {
+				t.Errorf("parseTracks returned no error for %q", body)
+			}
+		})
+	}
+
+	t.Run("parseTracks rejects too many tracks", func(t *testing.T) {
+		body := strings.Repeat("{}\n", app.MaxTrackBatch+1)
+
+		_, err := parseTracks(strings.NewReader(body))
+
+		if err != app.ErrTooManyTracks {
+			t.Errorf("parseTracks returned wrong error: got %v want %v",
+				err, app.ErrTooManyTracks)
+		}
+	})
+}
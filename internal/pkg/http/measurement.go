@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mattribution/api/internal/app"
+)
+
+// measurementPayload is a GA4 Measurement Protocol request
+type measurementPayload struct {
+	ClientID        string             `json:"client_id"`
+	UserID          string             `json:"user_id"`
+	TimestampMicros json.Number        `json:"timestamp_micros"`
+	Events          []measurementEvent `json:"events"`
+}
+
+type measurementEvent struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// toTracks maps every event in the payload onto a track. Campaigns come from
+// the event's campaign params, or from the UTM params of its page_location.
+func (p measurementPayload) toTracks() []app.Track {
+	sentAt := time.Now()
+	if micros, err := p.TimestampMicros.Int64(); err == nil {
+		sentAt = time.Unix(0, micros*int64(time.Microsecond))
+	}
+
+	tracks := make([]app.Track, len(p.Events))
+	for i, e := range p.Events {
+		param := func(name string) string {
+			s, _ := e.Params[name].(string)
+			return s
+		}
+
+		t := app.Track{
+			UserID:          p.UserID,
+			AnonymousID:     p.ClientID,
+			Event:           e.Name,
+			PageURL:         param("page_location"),
+			PageReferrer:    param("page_referrer"),
+			PageTitle:       param("page_title"),
+			CampaignName:    param("campaign"),
+			CampaignSource:  param("source"),
+			CampaignMedium:  param("medium"),
+			CampaignContent: param("content"),
+			SentAt:          sentAt,
+		}
+		if value, ok := e.Params["value"].(float64); ok {
+			t.Revenue = value
+		}
+
+		if location, err := url.Parse(t.PageURL); err == nil && t.PageURL != "" {
+			t.PagePath = location.Path
+			utm := location.Query()
+			utmParam := func(field *string, name string) {
+				if *field == "" {
+					*field = utm.Get(name)
+				}
+			}
+			utmParam(&t.CampaignName, "utm_campaign")
+			utmParam(&t.CampaignSource, "utm_source")
+			utmParam(&t.CampaignMedium, "utm_medium")
+			utmParam(&t.CampaignContent, "utm_content")
+		}
+
+		tracks[i] = t
+	}
+
+	return tracks
+}
+
+// measurementProtocol stores the events of a GA4 Measurement Protocol hit. The
+// api_secret query param is the owner secret.
+func (h *Handler) measurementProtocol(w http.ResponseWriter, r *http.Request) {
+	secret := r.URL.Query().Get("api_secret")
+
+	// Parse body
+	var payload measurementPayload
+	r.Body = http.MaxBytesReader(w, r.Body, maxTrackBytes)
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, invalidRequestError, http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+
+	// Grab IP
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	tracks := payload.toTracks()
+	for i := range tracks {
+		tracks[i].IP = ip
+	}
+
+	// Store raw tracks
+	_, err := h.service.NewTracks(tracks, secret)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == app.ErrUnknownSecret {
+		http.Error(w, unknownSecretError, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println("Error storing tracks: ", err)
+		return
+	}
+
+	// Google's endpoint responds without a body
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMeasurementPayloadToTracks(t *testing.T) {
+
+	t.Run("toTracks maps page params and UTM params onto tracks", func(t *testing.T) {
+		body := `{
+			"client_id": "123.456",
+			"timestamp_micros": 1577880000000000,
+			"events": [{
+				"name": "page_view",
+				"params": {
+					"page_location": "https://example.com/pricing?utm_source=google&utm_medium=cpc&utm_campaign=spring",
+					"page_referrer": "https://google.com",
+					"source": "newsletter"
+				}
+			}]
+		}`
+		var payload measurementPayload
+		if err := json.Unmarshal([]byte(body), &payload); err != nil {
+			t.Fatal(err)
+		}
+
+		tracks := payload.toTracks()
+
+		if len(tracks) != 1 {
+			t.Fatalf("toTracks returned wrong number of tracks: got %v want %v",
+				len(tracks), 1)
+		}
+		track := tracks[0]
+		if track.AnonymousID != "123.456" || track.Event != "page_view" || track.PagePath != "/pricing" || track.PageReferrer != "https://google.com" {
+			t.Errorf("toTracks returned unexpected track: got %+v", track)
+		}
+		if track.CampaignName != "spring" || track.CampaignSource != "newsletter" || track.CampaignMedium != "cpc" {
+			t.Errorf("toTracks returned unexpected campaign: got %+v", track)
+		}
+		if expected := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC); !track.SentAt.Equal(expected) {
+			t.Errorf("toTracks returned wrong sent at: got %v want %v",
+				track.SentAt, expected)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattribution/api/internal/app"
+)
+
+// Events stored for Segment calls that aren't tracks
+const (
+	segmentPageEvent     = "page"
+	segmentIdentifyEvent = "identify"
+	// maxSegmentBatchBytes is Segment's own limit on batch requests
+	maxSegmentBatchBytes = 500 << 10
+)
+
+// segmentMessage is a call to the Segment HTTP Tracking API
+type segmentMessage struct {
+	Type        string                 `json:"type"`
+	MessageID   string                 `json:"messageId"`
+	Event       string                 `json:"event"`
+	UserID      string                 `json:"userId"`
+	AnonymousID string                 `json:"anonymousId"`
+	Properties  map[string]interface{} `json:"properties"`
+	Context     segmentContext         `json:"context"`
+	Timestamp   time.Time              `json:"timestamp"`
+	SentAt      time.Time              `json:"sentAt"`
+}
+
+type segmentContext struct {
+	IP       string `json:"ip"`
+	Campaign struct {
+		Name    string `json:"name"`
+		Source  string `json:"source"`
+		Medium  string `json:"medium"`
+		Content string `json:"content"`
+	} `json:"campaign"`
+	Page struct {
+		URL      string `json:"url"`
+		Path     string `json:"path"`
+		Referrer string `json:"referrer"`
+		Title    string `json:"title"`
+	} `json:"page"`
+}
+
+type segmentBatch struct {
+	Batch []segmentMessage `json:"batch"`
+}
+
+// toTrack maps the message onto a track. Page details come from the context,
+// or from the properties of page calls.
+func (m segmentMessage) toTrack() app.Track {
+	t := app.Track{
+		MessageID:       m.MessageID,
+		UserID:          m.UserID,
+		AnonymousID:     m.AnonymousID,
+		Event:           m.Event,
+		IP:              m.Context.IP,
+		PageURL:         m.Context.Page.URL,
+		PagePath:        m.Context.Page.Path,
+		PageReferrer:    m.Context.Page.Referrer,
+		PageTitle:       m.Context.Page.Title,
+		CampaignName:    m.Context.Campaign.Name,
+		CampaignSource:  m.Context.Campaign.Source,
+		CampaignMedium:  m.Context.Campaign.Medium,
+		CampaignContent: m.Context.Campaign.Content,
+		SentAt:          m.Timestamp,
+	}
+
+	switch m.Type {
+	case "page":
+		t.Event = segmentPageEvent
+	case "identify":
+		t.Event = segmentIdentifyEvent
+	}
+
+	property := func(field *string, name string) {
+		if s, ok := m.Properties[name].(string); ok && *field == "" {
+			*field = s
+		}
+	}
+	property(&t.PageURL, "url")
+	property(&t.PagePath, "path")
+	property(&t.PageReferrer, "referrer")
+	property(&t.PageTitle, "title")
+	if revenue, ok := m.Properties["revenue"].(float64); ok {
+		t.Revenue = revenue
+	}
+
+	if t.SentAt.IsZero() {
+		t.SentAt = m.SentAt
+	}
+	if t.SentAt.IsZero() {
+		t.SentAt = time.Now()
+	}
+
+	return t
+}
+
+// segmentMessage stores a Segment track, page or identify call. The write key
+// is the owner secret, sent as the basic auth username.
+func (h *Handler) segmentMessage(w http.ResponseWriter, r *http.Request) {
+	secret, _, ok := r.BasicAuth()
+	if !ok {
+		http.Error(w, unknownSecretError, http.StatusUnauthorized)
+		return
+	}
+
+	// Parse body
+	var message segmentMessage
+	r.Body = http.MaxBytesReader(w, r.Body, maxTrackBytes)
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		http.Error(w, invalidRequestError, http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	message.Type = mux.Vars(r)["type"]
+
+	h.storeSegmentMessages(w, r, []segmentMessage{message}, secret)
+}
+
+// segmentBatch stores every call in a Segment batch
+func (h *Handler) segmentBatch(w http.ResponseWriter, r *http.Request) {
+	secret, _, ok := r.BasicAuth()
+	if !ok {
+		http.Error(w, unknownSecretError, http.StatusUnauthorized)
+		return
+	}
+
+	// Parse body
+	var batch segmentBatch
+	r.Body = http.MaxBytesReader(w, r.Body, maxSegmentBatchBytes)
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, invalidRequestError, http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+
+	h.storeSegmentMessages(w, r, batch.Batch, secret)
+}
+
+func (h *Handler) storeSegmentMessages(w http.ResponseWriter, r *http.Request, messages []segmentMessage, secret string) {
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	tracks := make([]app.Track, len(messages))
+	for i, m := range messages {
+		tracks[i] = m.toTrack()
+		if tracks[i].IP == "" {
+			tracks[i].IP = ip
+		}
+	}
+
+	// Store raw tracks
+	_, err := h.service.NewTracks(tracks, secret)
+	if _, ok := err.(app.ValidationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == app.ErrUnknownSecret {
+		http.Error(w, unknownSecretError, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		log.Println("Error storing tracks: ", err)
+		return
+	}
+
+	// Response in the shape Segment's API uses
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
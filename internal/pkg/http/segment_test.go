@@ -0,0 +1,54 @@
+package http
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSegmentMessageToTrack(t *testing.T) {
+
+	t.Run("toTrack maps the context and properties onto a track", func(t *testing.T) {
+		body := `{
+			"type": "track",
+			"messageId": "msg-1",
+			"event": "Order Completed",
+			"anonymousId": "abc123",
+			"properties": {"revenue": 49.5},
+			"context": {
+				"campaign": {"name": "Spring Sale", "source": "google", "medium": "cpc"},
+				"page": {"path": "/checkout", "referrer": "https://google.com"}
+			},
+			"timestamp": "2020-01-01T12:00:00.000Z"
+		}`
+		var message segmentMessage
+		if err := json.Unmarshal([]byte(body), &message); err != nil {
+			t.Fatal(err)
+		}
+
+		track := message.toTrack()
+
+		if track.Event != "Order Completed" || track.MessageID != "msg-1" || track.AnonymousID != "abc123" || track.Revenue != 49.5 {
+			t.Errorf("toTrack returned unexpected track: got %+v", track)
+		}
+		if track.CampaignName != "Spring Sale" || track.CampaignSource != "google" || track.CampaignMedium != "cpc" {
+			t.Errorf("toTrack returned unexpected campaign: got %+v", track)
+		}
+		if track.PagePath != "/checkout" || !track.SentAt.Equal(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("toTrack returned unexpected page: got %+v", track)
+		}
+	})
+
+	t.Run("toTrack reads page details from page call properties", func(t *testing.T) {
+		message := segmentMessage{
+			Type:       "page",
+			Properties: map[string]interface{}{"path": "/pricing", "title": "Pricing"},
+		}
+
+		track := message.toTrack()
+
+		if track.Event != segmentPageEvent || track.PagePath != "/pricing" || track.PageTitle != "Pricing" {
+			t.Errorf("toTrack returned unexpected track: got %+v", track)
+		}
+	})
+}
@@ -1,14 +1,16 @@
 package postgres
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/mattribution/api/internal/app"
 
-	// Import Postgres SQL driver
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	// Also imports the Postgres SQL driver
+	"github.com/lib/pq"
 )
 
 func NewCloudSQLClient(dbUser, dbPass, dbName, dbHost string) (*sqlx.DB, error) {
@@ -25,14 +27,22 @@ type TracksDAO struct {
 	DB *sqlx.DB
 }
 
+// Store stores the track, or returns the id of the owner's track with the same message id
+// if it was already stored. The unique index on the message id is created by
+// migrations/011_tracks_message_id.sql.
 func (dao *TracksDAO) Store(t app.Track) (int64, error) {
 	sqlStatement :=
-		`INSERT INTO public.tracks (owner_id, user_id, anonymous_id, page_url, page_path, page_referrer, page_title, event, campaign_source, campaign_medium, campaign_name, campaign_content, sent_at, created_at)
-	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		`INSERT INTO public.tracks (owner_id, message_id, user_id, anonymous_id, page_url, page_path, page_referrer, page_title, event, campaign_source, campaign_medium, campaign_name, campaign_content, revenue, sent_at, created_at)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	ON CONFLICT (owner_id, message_id) WHERE message_id <> '' DO NOTHING
 	RETURNING id`
 
 	var id int64
-	err := dao.DB.QueryRow(sqlStatement, t.OwnerID, t.UserID, t.AnonymousID, t.PageURL, t.PagePath, t.PageReferrer, t.PageTitle, t.Event, t.CampaignSource, t.CampaignMedium, t.CampaignName, t.CampaignContent, t.SentAt, time.Now().Format(time.RFC3339)).Scan(&id)
+	err := dao.DB.QueryRow(sqlStatement, t.OwnerID, t.MessageID, t.UserID, t.AnonymousID, t.PageURL, t.PagePath, t.PageReferrer, t.PageTitle, t.Event, t.CampaignSource, t.CampaignMedium, t.CampaignName, t.CampaignContent, t.Revenue, t.SentAt, time.Now().Format(time.RFC3339)).Scan(&id)
+	if err == sql.ErrNoRows {
+		// Nothing was inserted, so the track is a duplicate
+		err = dao.DB.Get(&id, `SELECT id FROM public.tracks WHERE owner_id = $1 AND message_id = $2`, t.OwnerID, t.MessageID)
+	}
 	if err != nil {
 		return id, err
 	}
@@ -40,35 +50,358 @@ func (dao *TracksDAO) Store(t app.Track) (int64, error) {
 	return id, nil
 }
 
-func (dao *TracksDAO) GetNormalizedJourneyAggregate(ownerID string, columnName, conversionColumnName, conversionRowValue string) ([]app.PosAggregate, error) {
+// StoreBatch stores every track with one insert and returns their ids in the same order.
+// Tracks with a message id that was already stored, in an earlier request or earlier in
+// the batch, get the id of the stored track instead. Ids are taken from the tracks id
+// sequence before inserting, so that inserted rows can be matched back to their tracks.
+func (dao *TracksDAO) StoreBatch(tracks []app.Track) ([]int64, error) {
+	if len(tracks) == 0 {
+		return []int64{}, nil
+	}
+
+	var reserved []int64
+	err := dao.DB.Select(&reserved, `SELECT nextval(pg_get_serial_sequence('public.tracks', 'id')) FROM generate_series(1, $1)`, len(tracks))
+	if err != nil {
+		return nil, err
+	}
+
+	q := &query{}
+	createdAt := time.Now().Format(time.RFC3339)
+	rows := make([]string, len(tracks))
+	for i, t := range tracks {
+		values := []interface{}{reserved[i], t.OwnerID, t.MessageID, t.UserID, t.AnonymousID, t.PageURL, t.PagePath, t.PageReferrer, t.PageTitle, t.Event, t.CampaignSource, t.CampaignMedium, t.CampaignName, t.CampaignContent, t.Revenue, t.SentAt, createdAt}
+		placeholders := make([]string, len(values))
+		for j, v := range values {
+			placeholders[j] = q.arg(v)
+		}
+		rows[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+	sqlStatement :=
+		`INSERT INTO public.tracks (id, owner_id, message_id, user_id, anonymous_id, page_url, page_path, page_referrer, page_title, event, campaign_source, campaign_medium, campaign_name, campaign_content, revenue, sent_at, created_at)
+	VALUES` + strings.Join(rows, ",\n\t") + `
+	ON CONFLICT (owner_id, message_id) WHERE message_id <> '' DO NOTHING
+	RETURNING id`
+
+	var inserted []int64
+	err = dao.DB.Select(&inserted, sqlStatement, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	wasInserted := map[int64]bool{}
+	for _, id := range inserted {
+		wasInserted[id] = true
+	}
+
+	// Only tracks with a message id can be duplicates. They weren't inserted, so look up
+	// the tracks they were already stored as.
+	ids := make([]int64, len(tracks))
+	var duplicates []int
+	for i := range tracks {
+		if wasInserted[reserved[i]] {
+			ids[i] = reserved[i]
+			continue
+		}
+		duplicates = append(duplicates, i)
+	}
+	if len(duplicates) == 0 {
+		return ids, nil
+	}
+
+	ownerIDs := make([]string, len(duplicates))
+	messageIDs := make([]string, len(duplicates))
+	for j, i := range duplicates {
+		ownerIDs[j] = tracks[i].OwnerID
+		messageIDs[j] = tracks[i].MessageID
+	}
+	var existing []storedTrack
+	err = dao.DB.Select(&existing, `
+		SELECT t.id, t.owner_id, t.message_id
+		FROM public.tracks AS t
+		JOIN unnest($1::text[], $2::text[]) AS d (owner_id, message_id)
+		ON t.owner_id = d.owner_id
+		AND t.message_id = d.message_id`, pq.Array(ownerIDs), pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	existingIDs := map[[2]string]int64{}
+	for _, e := range existing {
+		existingIDs[[2]string{e.OwnerID, e.MessageID}] = e.ID
+	}
+	for _, i := range duplicates {
+		ids[i] = existingIDs[[2]string{tracks[i].OwnerID, tracks[i].MessageID}]
+	}
+
+	return ids, nil
+}
+
+// storedTrack identifies a track that was stored
+type storedTrack struct {
+	ID        int64  `db:"id"`
+	OwnerID   string `db:"owner_id"`
+	MessageID string `db:"message_id"`
+}
+
+func (dao *TracksDAO) GetNormalizedJourneyAggregate(kpi app.Kpi, dimensions []string, dateRange app.DateRange) ([]app.PosAggregate, error) {
+	sqlStatement, q := journeyAggregateQuery(kpi, dimensions, dateRange)
+	if q.err != nil {
+		return nil, q.err
+	}
+	var posAggregates []app.PosAggregate
+	err := dao.DB.Select(&posAggregates, sqlStatement, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return posAggregates, err
+}
+
+// journeyAggregateQuery builds the SQL of GetNormalizedJourneyAggregate. Positions count
+// from each conversion's first touch, which is the one after the visitor's previous
+// conversion for kpis that count repeat conversions.
+func journeyAggregateQuery(kpi app.Kpi, dimensions []string, dateRange app.DateRange) (string, *query) {
+	q := &query{}
+	value, notEmpty := q.dimensions("t", dimensions)
 	sqlStatement :=
 		fmt.Sprintf(`
 		SELECT *, count(*)
 		FROM (
-			SELECT %s as value,
-			ROW_NUMBER() OVER (PARTITION BY anonymous_id ORDER BY sent_at) AS position,
-			date_trunc('day', sent_at) AS day
-			FROM tracks AS t
-			WHERE %s <> ''
-			AND owner_id = $1
-			AND t.sent_at < (
-				SELECT sent_at 
-				FROM tracks t2 
-				WHERE t2.%s = '%s' 
-				AND t.anonymous_id = t2.anonymous_id
-				ORDER  BY t2.created_at DESC
-				LIMIT 1
-			)
+			SELECT value,
+			ROW_NUMBER() OVER (PARTITION BY anonymous_id, conversion_sent_at ORDER BY sent_at) AS position,
+			day
+			FROM (
+				SELECT t.anonymous_id, %s AS value, t.sent_at,
+				%s AS day,
+				%s AS conversion_sent_at
+				FROM tracks AS t
+				WHERE %s
+				AND t.owner_id = %s
+			) AS touches
+			WHERE sent_at < conversion_sent_at
+			AND %s
 		) as tracks
 		GROUP BY position, value, day
-		ORDER BY day;`, columnName, columnName, conversionColumnName, conversionRowValue)
-	var posAggregates []app.PosAggregate
-	err := dao.DB.Select(&posAggregates, sqlStatement, ownerID)
+		ORDER BY day;`, value, q.day(dateRange), q.conversionSentAt(kpi, dateRange), notEmpty, q.arg(kpi.OwnerID), q.lookback(kpi))
+
+	return sqlStatement, q
+}
+
+// GetConversionJourneys returns every non-empty touch that happened before each visitor's
+// conversion within the kpi's lookback window, along with the conversion's revenue, ordered
+// so that touches for the same conversion are next to each other
+func (dao *TracksDAO) GetConversionJourneys(kpi app.Kpi, dimensions []string, dateRange app.DateRange) ([]app.JourneyTouch, error) {
+	sqlStatement, q := conversionJourneysQuery(kpi, dimensions, dateRange)
+	if q.err != nil {
+		return nil, q.err
+	}
+	var touches []app.JourneyTouch
+	err := dao.DB.Select(&touches, sqlStatement, q.args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return posAggregates, err
+	return touches, nil
+}
+
+// conversionJourneysQuery builds the SQL of GetConversionJourneys. Values are only bound
+// when the SQL uses them, since lib/pq rejects statements with unused parameters.
+func conversionJourneysQuery(kpi app.Kpi, dimensions []string, dateRange app.DateRange) (string, *query) {
+	q := &query{}
+	value, notEmpty := q.dimensions("t", dimensions)
+
+	// Only look up when visitors became leads if the kpi has a lead pattern
+	leadSelect := "NULL"
+	if kpi.LeadPatternMatchColumnName != "" {
+		leadSelect = fmt.Sprintf(`(
+				SELECT min(sent_at)
+				FROM tracks t3
+				WHERE %s = %s
+				AND t.anonymous_id = t3.anonymous_id
+				AND t3.owner_id = t.owner_id
+			)`, q.column("t3", kpi.LeadPatternMatchColumnName), q.arg(kpi.LeadPatternMatchRowValue))
+	}
+
+	sqlStatement :=
+		fmt.Sprintf(`
+		SELECT anonymous_id, value, sent_at, conversion_sent_at, lead_sent_at,
+		%s AS revenue
+		FROM (
+			SELECT t.anonymous_id, %s AS value, t.sent_at,
+			%s AS conversion_sent_at,
+			%s AS lead_sent_at
+			FROM tracks AS t
+			WHERE %s
+			AND t.owner_id = %s
+		) AS touches
+		WHERE sent_at < conversion_sent_at
+		AND %s
+		ORDER BY anonymous_id, conversion_sent_at, sent_at;`, q.conversionRevenue(kpi, "touches"), value, q.conversionSentAt(kpi, dateRange), leadSelect, notEmpty, q.arg(kpi.OwnerID), q.lookback(kpi))
+
+	return sqlStatement, q
+}
+
+// GetConversionTimeseries counts the conversions on the kpi in the date range per period.
+// Unless the kpi counts repeat conversions, only each visitor's latest conversion is
+// counted, like the journey queries do.
+func (dao *TracksDAO) GetConversionTimeseries(kpi app.Kpi, granularity string, dateRange app.DateRange) ([]app.TimeseriesPoint, error) {
+	q := &query{}
+	distinct := "DISTINCT ON (anonymous_id)"
+	if kpi.RepeatConversions {
+		distinct = ""
+	}
+	sqlStatement :=
+		fmt.Sprintf(`
+		SELECT period, count(*) AS conversions
+		FROM (
+			SELECT %s anonymous_id, %s AS period
+			FROM tracks AS t
+			WHERE %s
+			AND t.owner_id = %s
+			AND %s
+			ORDER BY anonymous_id, t.created_at DESC
+		) AS conversions
+		GROUP BY period
+		ORDER BY period;`, distinct, q.period(granularity, dateRange), q.condition("t", kpi.MatchCondition()), q.arg(kpi.OwnerID), q.sentIn("t", dateRange))
+	if q.err != nil {
+		return nil, q.err
+	}
+	var points []app.TimeseriesPoint
+	err := dao.DB.Select(&points, sqlStatement, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Periods come back as wall clock times in the date range's location
+	if dateRange.Location != nil {
+		for i, p := range points {
+			points[i].Period = time.Date(p.Period.Year(), p.Period.Month(), p.Period.Day(), 0, 0, 0, 0, dateRange.Location)
+		}
+	}
+
+	return points, nil
+}
+
+// GetVisitorJourneys returns the non-empty touches sent in the date range by every visitor,
+// converting or not. Touches after a visitor's conversion or before its lookback window are left out.
+// Touches that didn't lead to a conversion get the epoch as their conversion time.
+func (dao *TracksDAO) GetVisitorJourneys(kpi app.Kpi, dimensions []string, dateRange app.DateRange) ([]app.JourneyTouch, error) {
+	q := &query{}
+	value, notEmpty := q.dimensions("t", dimensions)
+	sqlStatement :=
+		fmt.Sprintf(`
+		SELECT anonymous_id, value, sent_at,
+		coalesce(conversion_sent_at, 'epoch') AS conversion_sent_at,
+		conversion_sent_at IS NOT NULL AS converted
+		FROM (
+			SELECT t.anonymous_id, %s AS value, t.sent_at,
+			%s AS conversion_sent_at
+			FROM tracks AS t
+			WHERE %s
+			AND t.owner_id = %s
+			AND %s
+		) AS touches
+		WHERE conversion_sent_at IS NULL
+		OR (sent_at < conversion_sent_at AND %s)
+		ORDER BY anonymous_id, sent_at;`, value, q.conversionSentAt(kpi, dateRange), notEmpty, q.arg(kpi.OwnerID), q.sentIn("t", dateRange), q.lookback(kpi))
+	if q.err != nil {
+		return nil, q.err
+	}
+	var touches []app.JourneyTouch
+	err := dao.DB.Select(&touches, sqlStatement, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return touches, nil
+}
+
+// GetFunnelTouches returns every track sent in the date range that met one of the funnel
+// kpi's steps, ordered by visitor and then sent_at
+func (dao *TracksDAO) GetFunnelTouches(kpi app.Kpi, dateRange app.DateRange) ([]app.FunnelTouch, error) {
+	q := &query{}
+	steps := make([]string, len(kpi.Steps))
+	for i, step := range kpi.Steps {
+		steps[i] = fmt.Sprintf(`
+			SELECT t.anonymous_id, %d AS step, t.sent_at
+			FROM tracks AS t
+			WHERE %s
+			AND t.owner_id = %s
+			AND %s`, i, q.condition("t", step), q.arg(kpi.OwnerID), q.sentIn("t", dateRange))
+	}
+	sqlStatement := strings.Join(steps, "\n\t\tUNION ALL") + "\n\t\tORDER BY anonymous_id, sent_at, step;"
+	if q.err != nil {
+		return nil, q.err
+	}
+	var touches []app.FunnelTouch
+	err := dao.DB.Select(&touches, sqlStatement, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return touches, nil
+}
+
+// GetTouchesForVisitors returns every non-empty touch sent by the visitors, ordered by
+// visitor and then sent_at
+func (dao *TracksDAO) GetTouchesForVisitors(ownerID string, dimensions []string, anonymousIDs []string) ([]app.JourneyTouch, error) {
+	q := &query{}
+	value, notEmpty := q.dimensions("t", dimensions)
+	sqlStatement :=
+		fmt.Sprintf(`
+		SELECT t.anonymous_id, %s AS value, t.sent_at
+		FROM tracks AS t
+		WHERE %s
+		AND t.owner_id = %s
+		AND t.anonymous_id = ANY(%s)
+		ORDER BY t.anonymous_id, t.sent_at;`, value, notEmpty, q.arg(ownerID), q.arg(pq.Array(anonymousIDs)))
+	if q.err != nil {
+		return nil, q.err
+	}
+	var touches []app.JourneyTouch
+	err := dao.DB.Select(&touches, sqlStatement, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return touches, nil
+}
+
+// GetFunnelRevenues returns what each visitor's funnel, completed at the matching time in
+// completedAt, was worth. The track completing the last step is the conversion.
+func (dao *TracksDAO) GetFunnelRevenues(kpi app.Kpi, anonymousIDs []string, completedAt []time.Time) ([]app.FunnelRevenue, error) {
+	// lib/pq can't bind time arrays, so they are sent as text and cast
+	times := make([]string, len(completedAt))
+	for i, t := range completedAt {
+		times[i] = t.Format(time.RFC3339Nano)
+	}
+
+	q := &query{}
+	sqlStatement := fmt.Sprintf(`
+		SELECT f.anonymous_id, %s AS revenue
+		FROM unnest(%s::text[], %s::timestamptz[]) AS f(anonymous_id, conversion_sent_at);`,
+		q.conversionRevenue(kpi, "f"), q.arg(pq.Array(anonymousIDs)), q.arg(pq.Array(times)))
+	if q.err != nil {
+		return nil, q.err
+	}
+	var revenues []app.FunnelRevenue
+	err := dao.DB.Select(&revenues, sqlStatement, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return revenues, nil
+}
+
+// invalidRegularExpression is the postgres error code for regexes it can't compile
+const invalidRegularExpression = "2201B"
+
+// CheckRegex returns app.ErrInvalidRegex if postgres can't run the pattern as a regex
+func (dao *TracksDAO) CheckRegex(pattern string) error {
+	var matches bool
+	err := dao.DB.Get(&matches, "SELECT '' ~ $1;", pattern)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == invalidRegularExpression {
+		return app.ErrInvalidRegex
+	}
+	return err
 }
 
 // ~=~=~=~=~=~=~=~=
@@ -82,12 +415,12 @@ type KpisDAO struct {
 
 func (dao *KpisDAO) Store(kpi app.Kpi) (int64, error) {
 	sqlStatement :=
-		`INSERT INTO public.kpis (owner_id, model_id, name, target, pattern_match_column_name, pattern_match_row_value, created_at)
-	VALUES($1, $2, $3, $4, $5, $6, $7)
+		`INSERT INTO public.kpis (owner_id, model_id, name, target, target_period, pattern_match_column_name, pattern_match_row_value, dimension, half_life_days, first_touch_weight, lead_touch_weight, last_touch_weight, lead_pattern_match_column_name, lead_pattern_match_row_value, lookback_days, repeat_conversions, condition, steps, funnel_window_days, created_at)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 	RETURNING id`
 
 	var id int64
-	err := dao.DB.QueryRow(sqlStatement, kpi.OwnerID, kpi.ModelID, kpi.Name, kpi.Target, kpi.PatternMatchColumnName, kpi.PatternMatchRowValue, time.Now().Format(time.RFC3339)).Scan(&id)
+	err := dao.DB.QueryRow(sqlStatement, kpi.OwnerID, kpi.ModelID, kpi.Name, kpi.Target, kpi.TargetPeriod, kpi.PatternMatchColumnName, kpi.PatternMatchRowValue, kpi.Dimension, kpi.HalfLifeDays, kpi.FirstTouchWeight, kpi.LeadTouchWeight, kpi.LastTouchWeight, kpi.LeadPatternMatchColumnName, kpi.LeadPatternMatchRowValue, kpi.LookbackDays, kpi.RepeatConversions, kpi.Condition, kpi.Steps, kpi.FunnelWindowDays, time.Now().Format(time.RFC3339)).Scan(&id)
 	if err != nil {
 		return id, err
 	}
@@ -95,6 +428,25 @@ func (dao *KpisDAO) Store(kpi app.Kpi) (int64, error) {
 	return id, nil
 }
 
+func (dao *KpisDAO) FindByID(id int64, ownerID string) (app.Kpi, error) {
+	sqlStatement :=
+		`SELECT * FROM public.kpis
+		WHERE id = $1
+		AND owner_id = $2`
+
+	var kpi app.Kpi
+
+	err := dao.DB.Get(&kpi, sqlStatement, id, ownerID)
+	if err == sql.ErrNoRows {
+		return kpi, app.ErrKpiNotFound
+	}
+	if err != nil {
+		return kpi, err
+	}
+
+	return kpi, nil
+}
+
 func (dao *KpisDAO) FindByOwnerID(ownerID string) ([]app.Kpi, error) {
 	sqlStatement :=
 		`SELECT * FROM public.kpis 
@@ -113,11 +465,14 @@ func (dao *KpisDAO) FindByOwnerID(ownerID string) ([]app.Kpi, error) {
 func (dao *KpisDAO) Update(kpi app.Kpi) error {
 	sqlStatement :=
 		`UPDATE public.kpis
-		SET target = $1, pattern_match_column_name = $2, pattern_match_row_value = $3, model_id = $4
-		WHERE id = $5
-		AND owner_id = $6`
+		SET target = $1, pattern_match_column_name = $2, pattern_match_row_value = $3, model_id = $4, dimension = $5, half_life_days = $6,
+		first_touch_weight = $7, lead_touch_weight = $8, last_touch_weight = $9, lead_pattern_match_column_name = $10, lead_pattern_match_row_value = $11,
+		lookback_days = $12, repeat_conversions = $13, condition = $14, steps = $15, funnel_window_days = $16, target_period = $17
+		WHERE id = $18
+		AND owner_id = $19`
 
-	_, err := dao.DB.Exec(sqlStatement, kpi.Target, kpi.PatternMatchColumnName, kpi.PatternMatchRowValue, kpi.ModelID, kpi.ID, kpi.OwnerID)
+	_, err := dao.DB.Exec(sqlStatement, kpi.Target, kpi.PatternMatchColumnName, kpi.PatternMatchRowValue, kpi.ModelID, kpi.Dimension, kpi.HalfLifeDays,
+		kpi.FirstTouchWeight, kpi.LeadTouchWeight, kpi.LastTouchWeight, kpi.LeadPatternMatchColumnName, kpi.LeadPatternMatchRowValue, kpi.LookbackDays, kpi.RepeatConversions, kpi.Condition, kpi.Steps, kpi.FunnelWindowDays, kpi.TargetPeriod, kpi.ID, kpi.OwnerID)
 	if err != nil {
 		return err
 	}
@@ -143,3 +498,134 @@ func (dao *KpisDAO) Delete(id int64, ownerID string) (int64, error) {
 
 	return count, nil
 }
+
+// ~=~=~=~=~=~=~=~=
+// Weights
+// ~=~=~=~=~=~=~=~=
+
+// WeightsDAO handles Weight data
+type WeightsDAO struct {
+	DB *sqlx.DB
+}
+
+func (dao *WeightsDAO) Store(weight app.Weight) (int64, error) {
+	sqlStatement :=
+		`INSERT INTO public.weights (owner_id, column_name, value, weight, created_at)
+	VALUES($1, $2, $3, $4, $5)
+	RETURNING id`
+
+	var id int64
+	err := dao.DB.QueryRow(sqlStatement, weight.OwnerID, weight.ColumnName, weight.Value, weight.Weight, time.Now().Format(time.RFC3339)).Scan(&id)
+	if err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+func (dao *WeightsDAO) FindByOwnerID(ownerID, columnName string) ([]app.Weight, error) {
+	sqlStatement :=
+		`SELECT * FROM public.weights
+		WHERE owner_id = $1
+		AND column_name = $2
+		ORDER BY value`
+
+	var weights []app.Weight
+
+	err := dao.DB.Select(&weights, sqlStatement, ownerID, columnName)
+	if err != nil {
+		return nil, err
+	}
+
+	return weights, nil
+}
+
+// FindUnweightedValues returns the distinct non-empty values of a tracks column that don't have a weight yet
+func (dao *WeightsDAO) FindUnweightedValues(ownerID, columnName string) ([]string, error) {
+	q := &query{}
+	column := q.column("t", columnName)
+	sqlStatement :=
+		fmt.Sprintf(`
+		SELECT DISTINCT %s
+		FROM tracks AS t
+		WHERE t.owner_id = %s
+		AND %s <> ''
+		AND NOT EXISTS (
+			SELECT 1
+			FROM weights w
+			WHERE w.owner_id = t.owner_id
+			AND w.column_name = %s
+			AND w.value = %s
+		)
+		ORDER BY 1;`, column, q.arg(ownerID), column, q.arg(columnName), column)
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	var values []string
+
+	err := dao.DB.Select(&values, sqlStatement, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (dao *WeightsDAO) Update(weight app.Weight) error {
+	sqlStatement :=
+		`UPDATE public.weights
+		SET column_name = $1, value = $2, weight = $3
+		WHERE id = $4
+		AND owner_id = $5`
+
+	_, err := dao.DB.Exec(sqlStatement, weight.ColumnName, weight.Value, weight.Weight, weight.ID, weight.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dao *WeightsDAO) Delete(id int64, ownerID string) (int64, error) {
+	sqlStatement :=
+		`DELETE FROM public.weights
+		WHERE id = $1
+		AND owner_id = $2`
+
+	res, err := dao.DB.Exec(sqlStatement, id, ownerID)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// ~=~=~=~=~=~=~=~=
+// Billing events
+// ~=~=~=~=~=~=~=~=
+
+// BillingEventsDAO handles BillingEvent data
+type BillingEventsDAO struct {
+	DB *sqlx.DB
+}
+
+func (dao *BillingEventsDAO) Store(e app.BillingEvent) (int64, error) {
+	sqlStatement :=
+		`INSERT INTO public.billing_events (owner_id, user_id, amount, sent_at, created_at)
+	VALUES($1, $2, $3, $4, $5)
+	RETURNING id`
+
+	var id int64
+	err := dao.DB.QueryRow(sqlStatement, e.OwnerID, e.UserID, e.Amount, e.SentAt, time.Now().Format(time.RFC3339)).Scan(&id)
+	if err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
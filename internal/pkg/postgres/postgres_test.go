@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mattribution/api/internal/app"
+)
+
+func TestConversionJourneysQuery(t *testing.T) {
+	placeholder := regexp.MustCompile(`\$(\d+)`)
+	kpis := map[string]app.Kpi{
+		"without a lead pattern": {PatternMatchColumnName: "event", PatternMatchRowValue: "signup"},
+		"with a lead pattern": {
+			PatternMatchColumnName:     "event",
+			PatternMatchRowValue:       "signup",
+			LeadPatternMatchColumnName: "event",
+			LeadPatternMatchRowValue:   "lead",
+		},
+	}
+
+	for name, kpi := range kpis {
+		t.Run("conversionJourneysQuery binds only the args it uses "+name, func(t *testing.T) {
+			sql, q := conversionJourneysQuery(kpi, []string{app.DefaultDimension}, app.DateRange{})
+
+			if q.err != nil {
+				t.Fatal(q.err)
+			}
+			used := map[int]bool{}
+			for _, match := range placeholder.FindAllStringSubmatch(sql, -1) {
+				n, _ := strconv.Atoi(match[1])
+				used[n] = true
+			}
+			if len(used) != len(q.args) {
+				t.Errorf("conversionJourneysQuery bound %v args but uses %v", len(q.args), len(used))
+			}
+			for i := 1; i <= len(q.args); i++ {
+				if !used[i] {
+					t.Errorf("conversionJourneysQuery doesn't use $%v", i)
+				}
+			}
+		})
+	}
+}
+
+func TestJourneyAggregateQuery(t *testing.T) {
+	kpi := app.Kpi{PatternMatchColumnName: "event", PatternMatchRowValue: "purchase", RepeatConversions: true}
+
+	t.Run("journeyAggregateQuery restarts positions at each conversion", func(t *testing.T) {
+		sql, q := journeyAggregateQuery(kpi, []string{app.DefaultDimension}, app.DateRange{})
+
+		if q.err != nil {
+			t.Fatal(q.err)
+		}
+		partition := "PARTITION BY anonymous_id, conversion_sent_at ORDER BY sent_at"
+		if !strings.Contains(sql, partition) {
+			t.Errorf("journeyAggregateQuery doesn't number touches per conversion: got %v want it to contain %v",
+				sql, partition)
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattribution/api/internal/app"
+)
+
+// query collects the parameters of a SQL statement as it is built, so values
+// are always bound instead of spliced into the SQL. Identifiers can only be
+// track columns, and the first unknown one is kept in err.
+type query struct {
+	args []interface{}
+	err  error
+}
+
+// arg binds v as the next parameter and returns its placeholder
+func (q *query) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// column returns the quoted track column name of the table aliased as alias
+func (q *query) column(alias, name string) string {
+	if !app.IsTrackColumn(name) {
+		if q.err == nil {
+			q.err = fmt.Errorf("%q is not a track column", name)
+		}
+		return ""
+	}
+	return alias + "." + pq.QuoteIdentifier(name)
+}
+
+// dimensions returns SQL that selects the dimensions of the track aliased as alias as one
+// value, separated by app.DimensionSeparator, and SQL that is true when any of them are set
+func (q *query) dimensions(alias string, dimensions []string) (string, string) {
+	columns := make([]string, len(dimensions))
+	conditions := make([]string, len(dimensions))
+	for i, d := range dimensions {
+		columns[i] = q.column(alias, d)
+		conditions[i] = columns[i] + " <> ''"
+	}
+
+	value := columns[0]
+	if len(columns) > 1 {
+		value = fmt.Sprintf("concat_ws(%s, %s)", q.arg(app.DimensionSeparator), strings.Join(columns, ", "))
+	}
+
+	return value, "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+// condition returns SQL that is true when the track aliased as alias meets the condition
+func (q *query) condition(alias string, c app.Condition) string {
+	switch {
+	case c.And != nil:
+		return q.conditions(alias, c.And, " AND ")
+	case c.Or != nil:
+		return q.conditions(alias, c.Or, " OR ")
+	case c.Not != nil:
+		return "NOT (" + q.condition(alias, *c.Not) + ")"
+	}
+
+	column := q.column(alias, c.Column)
+	switch c.Operator {
+	case app.EqualsOperator:
+		return fmt.Sprintf("%s = %s", column, q.arg(c.Operand))
+	case app.ContainsOperator:
+		return fmt.Sprintf("%s LIKE %s", column, q.arg("%"+escapeLike(c.Operand)+"%"))
+	case app.PrefixOperator:
+		return fmt.Sprintf("%s LIKE %s", column, q.arg(escapeLike(c.Operand)+"%"))
+	case app.RegexOperator:
+		return fmt.Sprintf("%s ~ %s", column, q.arg(c.Operand))
+	case app.InOperator:
+		return fmt.Sprintf("%s = ANY(%s)", column, q.arg(pq.Array(c.Operands)))
+	}
+
+	if q.err == nil {
+		q.err = fmt.Errorf("%q is not a condition operator", c.Operator)
+	}
+	return ""
+}
+
+// conditions returns SQL that joins the conditions with the operator
+func (q *query) conditions(alias string, conditions []app.Condition, operator string) string {
+	if len(conditions) == 0 && q.err == nil {
+		q.err = errors.New("conditions can't be empty")
+	}
+	sql := make([]string, len(conditions))
+	for i, c := range conditions {
+		sql[i] = q.condition(alias, c)
+	}
+	return "(" + strings.Join(sql, operator) + ")"
+}
+
+// escapeLike escapes the characters LIKE treats as wildcards
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// conversionSentAt returns a subquery selecting when the visitor of the track
+// aliased as t converted on the kpi within the date range. Kpis that count repeat
+// conversions use the visitor's first conversion after the track, so each
+// conversion only gets the touches since the one before it.
+func (q *query) conversionSentAt(kpi app.Kpi, dateRange app.DateRange) string {
+	if kpi.RepeatConversions {
+		return fmt.Sprintf(`(
+					SELECT next.sent_at
+					FROM (
+						SELECT min(t2.sent_at) AS sent_at
+						FROM tracks t2
+						WHERE %s
+						AND t.anonymous_id = t2.anonymous_id
+						AND t2.owner_id = t.owner_id
+						AND t2.sent_at > t.sent_at
+					) AS next
+					WHERE %s
+				)`, q.condition("t2", kpi.MatchCondition()), q.sentIn("next", dateRange))
+	}
+	return fmt.Sprintf(`(
+				SELECT sent_at
+				FROM tracks t2
+				WHERE %s
+				AND t.anonymous_id = t2.anonymous_id
+				AND t2.owner_id = t.owner_id
+				AND %s
+				ORDER BY t2.created_at DESC
+				LIMIT 1
+			)`, q.condition("t2", kpi.MatchCondition()), q.sentIn("t2", dateRange))
+}
+
+// conversionRevenue returns a subquery selecting what the conversion of the row aliased
+// as alias was worth. That is the revenue of the conversion track plus every billing event
+// of the visitor's users from the conversion on, or until the next conversion for kpis that
+// count repeat conversions. The row needs anonymous_id and conversion_sent_at columns.
+func (q *query) conversionRevenue(kpi app.Kpi, alias string) string {
+	owner := q.arg(kpi.OwnerID)
+	untilNext := "TRUE"
+	if kpi.RepeatConversions {
+		untilNext = fmt.Sprintf(`NOT EXISTS (
+						SELECT 1
+						FROM tracks n
+						WHERE %s
+						AND n.anonymous_id = %s.anonymous_id
+						AND n.owner_id = %s
+						AND n.sent_at > %s.conversion_sent_at
+						AND n.sent_at <= b.sent_at
+					)`, q.condition("n", kpi.MatchCondition()), alias, owner, alias)
+	}
+
+	return fmt.Sprintf(`(
+				SELECT coalesce(sum(c.revenue), 0)
+				FROM tracks c
+				WHERE %s
+				AND c.anonymous_id = %s.anonymous_id
+				AND c.owner_id = %s
+				AND c.sent_at = %s.conversion_sent_at
+			) + (
+				SELECT coalesce(sum(b.amount), 0)
+				FROM billing_events b
+				WHERE b.owner_id = %s
+				AND b.user_id IN (
+					SELECT u.user_id
+					FROM tracks u
+					WHERE u.anonymous_id = %s.anonymous_id
+					AND u.owner_id = %s
+					AND u.user_id <> ''
+				)
+				AND b.sent_at >= %s.conversion_sent_at
+				AND %s
+			)`, q.condition("c", kpi.MatchCondition()), alias, owner, alias, owner, alias, owner, alias, untilNext)
+}
+
+// lookback returns SQL that is true when a touch's sent_at is within the kpi's lookback
+// window before its conversion_sent_at
+func (q *query) lookback(kpi app.Kpi) string {
+	if kpi.LookbackDays <= 0 {
+		return "TRUE"
+	}
+	return fmt.Sprintf("sent_at >= conversion_sent_at - %s * interval '1 day'", q.arg(kpi.LookbackDays))
+}
+
+// sentIn returns SQL that is true when the track aliased as alias was sent in the date range
+func (q *query) sentIn(alias string, dateRange app.DateRange) string {
+	conditions := []string{"TRUE"}
+	if !dateRange.From.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("%s.sent_at >= %s", alias, q.arg(dateRange.From)))
+	}
+	if !dateRange.To.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("%s.sent_at < %s", alias, q.arg(dateRange.To)))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// day returns SQL that truncates sent_at to the day it was in the date range's location
+func (q *query) day(dateRange app.DateRange) string {
+	if dateRange.Location == nil {
+		return "date_trunc('day', sent_at)"
+	}
+	return fmt.Sprintf("date_trunc('day', sent_at AT TIME ZONE %s)", q.arg(dateRange.Location.String()))
+}
+
+// period returns SQL that truncates sent_at to the start of the day, week or month
+// it was in, in the date range's location
+func (q *query) period(granularity string, dateRange app.DateRange) string {
+	if dateRange.Location == nil {
+		return fmt.Sprintf("date_trunc(%s, sent_at)", q.arg(granularity))
+	}
+	return fmt.Sprintf("date_trunc(%s, sent_at AT TIME ZONE %s)", q.arg(granularity), q.arg(dateRange.Location.String()))
+}
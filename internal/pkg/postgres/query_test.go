@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mattribution/api/internal/app"
+)
+
+func TestQuery(t *testing.T) {
+
+	t.Run("arg binds values as numbered parameters", func(t *testing.T) {
+		q := &query{}
+
+		first := q.arg("signup")
+		second := q.arg("owner")
+
+		if first != "$1" || second != "$2" {
+			t.Errorf("arg returned unexpected placeholders: got %v and %v want $1 and $2",
+				first, second)
+		}
+		if !reflect.DeepEqual(q.args, []interface{}{"signup", "owner"}) {
+			t.Errorf("arg bound unexpected args: got %v", q.args)
+		}
+	})
+
+	t.Run("column quotes track columns", func(t *testing.T) {
+		q := &query{}
+
+		column := q.column("t", "campaign_name")
+
+		if column != `t."campaign_name"` || q.err != nil {
+			t.Errorf("column returned unexpected identifier: got %v (%v) want %v",
+				column, q.err, `t."campaign_name"`)
+		}
+	})
+
+	t.Run("column rejects anything that isn't a track column", func(t *testing.T) {
+		q := &query{}
+
+		q.column("t", "event = 'signup' OR 1=1; --")
+
+		if q.err == nil {
+			t.Error("column accepted an unknown column")
+		}
+	})
+
+	t.Run("dimensions joins several columns with the dimension separator", func(t *testing.T) {
+		q := &query{}
+		expectedValue := `concat_ws($1, t."campaign_source", t."campaign_medium")`
+		expectedNotEmpty := `(t."campaign_source" <> '' OR t."campaign_medium" <> '')`
+
+		value, notEmpty := q.dimensions("t", []string{"campaign_source", "campaign_medium"})
+
+		if value != expectedValue {
+			t.Errorf("dimensions returned unexpected value: got %v want %v",
+				value, expectedValue)
+		}
+		if notEmpty != expectedNotEmpty {
+			t.Errorf("dimensions returned unexpected condition: got %v want %v",
+				notEmpty, expectedNotEmpty)
+		}
+		if !reflect.DeepEqual(q.args, []interface{}{app.DimensionSeparator}) {
+			t.Errorf("dimensions bound unexpected args: got %v", q.args)
+		}
+	})
+
+	t.Run("lookback limits touches to the kpi's window", func(t *testing.T) {
+		q := &query{}
+		expected := "sent_at >= conversion_sent_at - $1 * interval '1 day'"
+
+		condition := q.lookback(app.Kpi{LookbackDays: 30})
+
+		if condition != expected {
+			t.Errorf("lookback returned unexpected condition: got %v want %v",
+				condition, expected)
+		}
+		if !reflect.DeepEqual(q.args, []interface{}{int64(30)}) {
+			t.Errorf("lookback bound unexpected args: got %v", q.args)
+		}
+	})
+
+	t.Run("lookback doesn't limit kpis without a window", func(t *testing.T) {
+		q := &query{}
+
+		if condition := q.lookback(app.Kpi{}); condition != "TRUE" {
+			t.Errorf("lookback returned unexpected condition: got %v want TRUE", condition)
+		}
+	})
+
+	t.Run("conversionSentAt uses the next conversion for repeat conversions", func(t *testing.T) {
+		q := &query{}
+		kpi := app.Kpi{PatternMatchColumnName: "event", PatternMatchRowValue: "purchase", RepeatConversions: true}
+
+		subquery := q.conversionSentAt(kpi, app.DateRange{})
+
+		if !strings.Contains(subquery, "AND t2.sent_at > t.sent_at") || strings.Contains(subquery, "LIMIT 1") {
+			t.Errorf("conversionSentAt returned unexpected subquery: got %v", subquery)
+		}
+		if !reflect.DeepEqual(q.args, []interface{}{"purchase"}) {
+			t.Errorf("conversionSentAt bound unexpected args: got %v", q.args)
+		}
+	})
+
+	t.Run("condition compiles a condition tree with bound operands", func(t *testing.T) {
+		q := &query{}
+		c := app.Condition{And: []app.Condition{
+			{Column: "event", Operator: app.EqualsOperator, Operand: "signup"},
+			{Not: &app.Condition{Column: "page_path", Operator: app.PrefixOperator, Operand: "/100%_off"}},
+		}}
+		expected := `(t."event" = $1 AND NOT (t."page_path" LIKE $2))`
+
+		sql := q.condition("t", c)
+
+		if sql != expected || q.err != nil {
+			t.Errorf("condition returned unexpected SQL: got %v (%v) want %v",
+				sql, q.err, expected)
+		}
+		if !reflect.DeepEqual(q.args, []interface{}{"signup", `/100\%\_off%`}) {
+			t.Errorf("condition bound unexpected args: got %v", q.args)
+		}
+	})
+
+	t.Run("condition rejects columns that aren't track columns", func(t *testing.T) {
+		q := &query{}
+
+		q.condition("t", app.Condition{Column: "1=1 OR event", Operator: app.EqualsOperator})
+
+		if q.err == nil {
+			t.Error("condition accepted an unknown column")
+		}
+	})
+}
@@ -0,0 +1,2 @@
+-- How quickly credit halves with a touch's age under the time-decay model, 0 for the default
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS half_life_days double precision NOT NULL DEFAULT 0;
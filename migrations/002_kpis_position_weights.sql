@@ -0,0 +1,7 @@
+-- Position weights of the u-shaped and w-shaped models, 0 for the defaults, and the
+-- pattern the w-shaped model finds the lead touch with
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS first_touch_weight double precision NOT NULL DEFAULT 0;
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS lead_touch_weight double precision NOT NULL DEFAULT 0;
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS last_touch_weight double precision NOT NULL DEFAULT 0;
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS lead_pattern_match_column_name text NOT NULL DEFAULT '';
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS lead_pattern_match_row_value text NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+-- Credit the weighted model gives to each value of a track column, per owner
+CREATE TABLE IF NOT EXISTS public.weights (
+	id bigserial PRIMARY KEY,
+	owner_id text NOT NULL,
+	column_name text NOT NULL,
+	value text NOT NULL,
+	weight double precision NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS weights_owner_id_column_name ON public.weights (owner_id, column_name);
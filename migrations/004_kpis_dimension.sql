@@ -0,0 +1,2 @@
+-- Comma separated track columns a kpi's conversions are attributed to, empty for campaign_name
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS dimension text NOT NULL DEFAULT '';
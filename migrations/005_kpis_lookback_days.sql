@@ -0,0 +1,2 @@
+-- How long before a conversion touches are counted, 0 for no limit
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS lookback_days bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Whether a kpi counts every conversion instead of each visitor's latest
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS repeat_conversions boolean NOT NULL DEFAULT false;
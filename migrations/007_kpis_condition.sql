@@ -0,0 +1,2 @@
+-- Condition a kpi matches conversions with instead of its column and value, stored as JSON
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS condition jsonb;
@@ -0,0 +1,3 @@
+-- Steps of funnel kpis, stored as JSON, and how long visitors have to complete them, 0 for no limit
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS steps jsonb;
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS funnel_window_days bigint NOT NULL DEFAULT 0;
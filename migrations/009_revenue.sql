@@ -0,0 +1,14 @@
+-- What a conversion track was worth, and payments made by an owner's users that add
+-- to the revenue of their conversions
+ALTER TABLE public.tracks ADD COLUMN IF NOT EXISTS revenue numeric NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS public.billing_events (
+	id bigserial PRIMARY KEY,
+	owner_id text NOT NULL,
+	user_id text NOT NULL,
+	amount numeric NOT NULL,
+	sent_at timestamptz NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS billing_events_owner_id_user_id ON public.billing_events (owner_id, user_id, sent_at);
@@ -0,0 +1,2 @@
+-- Period a kpi's target is for, empty for the default
+ALTER TABLE public.kpis ADD COLUMN IF NOT EXISTS target_period text NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- Tracks can be sent with a message id, so that retried tracks aren't stored twice.
+-- The index makes message ids unique per owner and is what StoreBatch and Store's
+-- ON CONFLICT (owner_id, message_id) WHERE message_id <> '' clause rely on.
+ALTER TABLE public.tracks ADD COLUMN IF NOT EXISTS message_id text NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS tracks_owner_id_message_id
+	ON public.tracks (owner_id, message_id)
+	WHERE message_id <> '';
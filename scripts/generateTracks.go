@@ -1,15 +1,13 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
+	"os"
 	"strconv"
 	"time"
 
-	b64 "encoding/base64"
-
 	"github.com/cheggaaa/pb/v3"
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
@@ -18,6 +16,9 @@ import (
 	wr "github.com/mroth/weightedrand"
 )
 
+// batchSize is how many tracks are sent per request
+const batchSize = 100
+
 func main() {
 	rand.Seed(time.Now().UTC().UnixNano()) // always seed random!
 
@@ -28,6 +29,15 @@ func main() {
 	trackLoopMax := 3
 	baseURL := "https://mattribution.com"
 	bar := pb.StartNew(userCount)
+	// Tracks are sent in batches rather than one request each
+	var batch []app.Track
+	storeTrack := func(t app.Track) {
+		batch = append(batch, t)
+		if len(batch) == batchSize {
+			storeTracks(batch)
+			batch = nil
+		}
+	}
 
 	convertTrack := app.Track{
 		OwnerID:  ownerID,
@@ -203,22 +213,25 @@ func main() {
 
 		bar.Increment()
 	}
+	storeTracks(batch)
 
 	bar.Finish()
 }
 
-func storeTrack(t app.Track) {
-	jsonBytes, err := json.Marshal(t)
-	if err != nil {
-		panic(err)
+func storeTracks(tracks []app.Track) {
+	if len(tracks) == 0 {
+		return
 	}
-	b64Str := b64.StdEncoding.EncodeToString(jsonBytes)
 	// Create a Resty Client
-	uri := fmt.Sprintf("http://localhost:3001/tracks/new?data=%v", b64Str)
+	uri := fmt.Sprintf("http://localhost:3001/tracks/batch?secret=%v", os.Getenv("SECRET"))
 	client := resty.New()
-	resp, err := client.R().Get(uri)
+	resp, err := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(tracks).
+		Post(uri)
 	if err != nil {
 		log.Printf("ERROR: %s", err)
+		return
 	}
 	if resp.StatusCode() != 200 {
 		log.Println(resp.Status())